@@ -0,0 +1,38 @@
+// Package example shows the code generated by ggtrpc-gen.
+package example
+
+import (
+	"errors"
+	"time"
+)
+
+//go:generate go run .. -type Arith
+
+type Args struct{ A, B int }
+
+type Quotient struct{ Quo, Rem int }
+
+// Arith is the contract of the arithmetic service.
+type Arith interface {
+	Sum(args Args) (int, error)
+	Div(args Args) (Quotient, error)
+	After(d time.Duration) (time.Time, error)
+}
+
+// arith implements Arith.
+type arith struct{}
+
+func (arith) Sum(args Args) (int, error) {
+	return args.A + args.B, nil
+}
+
+func (arith) Div(args Args) (Quotient, error) {
+	if args.B == 0 {
+		return Quotient{}, errors.New("divide by zero")
+	}
+	return Quotient{Quo: args.A / args.B, Rem: args.A % args.B}, nil
+}
+
+func (arith) After(d time.Duration) (time.Time, error) {
+	return time.Date(2000, 1, 1, 0, 0, 0, 0, time.UTC).Add(d), nil
+}
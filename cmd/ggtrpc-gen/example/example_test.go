@@ -0,0 +1,39 @@
+package example
+
+import (
+	"context"
+	"github.com/GallifreyGoTutoural/ggt-rpc/internal/testutil"
+	"github.com/GallifreyGoTutoural/ggt-rpc/rpc"
+	"github.com/GallifreyGoTutoural/ggt-rpc/xclient"
+	"net"
+	"testing"
+	"time"
+)
+
+func TestArith(t *testing.T) {
+	server := rpc.NewServer()
+	testutil.Assert(RegisterArith(server, arith{}) == nil, "RegisterArith() failed")
+	l, _ := net.Listen("tcp", "127.0.0.1:0")
+	defer l.Close()
+	go server.Accept(l)
+
+	client, err := rpc.Dial("tcp", l.Addr().String())
+	testutil.Assert(err == nil, "Dial() error:%v", err)
+	defer client.Close()
+	xc := xclient.NewXClient(xclient.NewMultiServerDiscovery([]string{"tcp@" + l.Addr().String()}), xclient.RandomSelect, nil)
+	defer xc.Close()
+
+	for name, c := range map[string]*ArithClient{"client": NewArithClient(client), "xclient": NewArithClient(xc)} {
+		t.Run(name, func(t *testing.T) {
+			ctx := context.Background()
+			sum, err := c.Sum(ctx, Args{A: 1, B: 2})
+			testutil.Assert(err == nil && sum == 3, "Sum error:%v reply:%d", err, sum)
+			q, err := c.Div(ctx, Args{A: 7, B: 2})
+			testutil.Assert(err == nil && q == Quotient{Quo: 3, Rem: 1}, "Div error:%v reply:%+v", err, q)
+			_, err = c.Div(ctx, Args{A: 7})
+			testutil.Assert(err != nil && err.Error() == "divide by zero", "expect the error of the method, but got %v", err)
+			at, err := c.After(ctx, time.Hour)
+			testutil.Assert(err == nil && at.Hour() == 1, "After error:%v reply:%s", err, at)
+		})
+	}
+}
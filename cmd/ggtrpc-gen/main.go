@@ -0,0 +1,265 @@
+// Command ggtrpc-gen generates typed clients and server adapters from Go interfaces, for go generate.
+//
+// Every method of the interface takes one argument and returns a reply and an error,
+// the argument and reply being of exported or builtin types as Server.Register requires:
+//
+//	//go:generate ggtrpc-gen -type Arith
+//	type Arith interface {
+//		Sum(args Args) (int, error)
+//	}
+//
+// For the interface Arith, it generates into arith_ggtrpc.go:
+//   - ArithClient, whose methods take a context and call the service over an rpc.Caller,
+//     such as rpc.Client or xclient.XClient
+//   - ArithService, which adapts an Arith to Server.Register, and names the service
+//   - RegisterArith, which registers an Arith on a Server
+//
+// Implementations and calls that don't match the interface fail to compile.
+package main
+
+import (
+	"bytes"
+	"errors"
+	"flag"
+	"fmt"
+	"go/ast"
+	"go/build"
+	"go/format"
+	"go/parser"
+	"go/printer"
+	"go/token"
+	"go/types"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+const rpcPath = "github.com/GallifreyGoTutoural/ggt-rpc/rpc"
+
+func main() {
+	if err := run(os.Args[1:]); err != nil {
+		fmt.Fprintln(os.Stderr, "ggtrpc-gen:", err)
+		os.Exit(1)
+	}
+}
+
+func run(args []string) error {
+	flags := flag.NewFlagSet("ggtrpc-gen", flag.ContinueOnError)
+	typeName := flags.String("type", "", "name of the interface")
+	file := flags.String("file", os.Getenv("GOFILE"), "file declaring the interface, $GOFILE by default")
+	output := flags.String("output", "", "output file, <type>_ggtrpc.go next to the file by default")
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+	if *typeName == "" || *file == "" {
+		flags.Usage()
+		return errors.New("-type and -file are required")
+	}
+	src, err := generate(*file, *typeName)
+	if err != nil {
+		return err
+	}
+	if *output == "" {
+		*output = filepath.Join(filepath.Dir(*file), strings.ToLower(*typeName)+"_ggtrpc.go")
+	}
+	return os.WriteFile(*output, src, 0644)
+}
+
+// method is a method of the interface.
+type method struct {
+	Name      string
+	ArgType   string
+	ReplyType string
+}
+
+// generate returns the source generated for the interface typeName declared in file.
+func generate(file, typeName string) ([]byte, error) {
+	fset := token.NewFileSet()
+	f, err := parser.ParseFile(fset, file, nil, parser.ParseComments)
+	if err != nil {
+		return nil, err
+	}
+	iface, err := findInterface(f, typeName)
+	if err != nil {
+		return nil, err
+	}
+	var methods []method
+	used := make(map[string]bool) // packages used by the types of the methods
+	for _, field := range iface.Methods.List {
+		fn, ok := field.Type.(*ast.FuncType)
+		if !ok || len(field.Names) != 1 {
+			return nil, fmt.Errorf("%s: embedded interfaces are not supported", fset.Position(field.Pos()))
+		}
+		name := field.Names[0].Name
+		if fieldCount(fn.Params) != 1 || fieldCount(fn.Results) != 2 {
+			return nil, fmt.Errorf("%s: %s.%s must take one argument and return a reply and an error",
+				fset.Position(field.Pos()), typeName, name)
+		}
+		argType, replyType, errType := fn.Params.List[0].Type, fn.Results.List[0].Type, fn.Results.List[len(fn.Results.List)-1].Type
+		if ident, ok := errType.(*ast.Ident); !ok || ident.Name != "error" {
+			return nil, fmt.Errorf("%s: %s.%s must return an error last", fset.Position(field.Pos()), typeName, name)
+		}
+		if _, ok := argType.(*ast.Ellipsis); ok {
+			return nil, fmt.Errorf("%s: %s.%s must not be variadic", fset.Position(field.Pos()), typeName, name)
+		}
+		if !isExportedOrBuiltinType(argType) || !isExportedOrBuiltinType(replyType) {
+			return nil, fmt.Errorf("%s: %s.%s must take and return exported or builtin types, or the server skips it",
+				fset.Position(field.Pos()), typeName, name)
+		}
+		collectPackages(argType, used)
+		collectPackages(replyType, used)
+		methods = append(methods, method{Name: name, ArgType: exprString(fset, argType), ReplyType: exprString(fset, replyType)})
+	}
+	if len(methods) == 0 {
+		return nil, fmt.Errorf("%s has no methods", typeName)
+	}
+
+	var buf bytes.Buffer
+	fmt.Fprintf(&buf, "// Code generated by ggtrpc-gen -type %s; DO NOT EDIT.\n\n", typeName)
+	fmt.Fprintf(&buf, "package %s\n\n", f.Name.Name)
+	fmt.Fprintf(&buf, "import (\n\t\"context\"\n\t%q\n", rpcPath)
+	dir, err := filepath.Abs(filepath.Dir(file))
+	if err != nil {
+		return nil, err
+	}
+	specs, err := imports(f, dir, used)
+	if err != nil {
+		return nil, err
+	}
+	for _, imp := range specs {
+		fmt.Fprintf(&buf, "\t%s\n", imp)
+	}
+	fmt.Fprintf(&buf, ")\n\n")
+
+	client, service := typeName+"Client", typeName+"Service"
+	fmt.Fprintf(&buf, "// %s calls the methods of %s over an rpc.Caller.\n", client, typeName)
+	fmt.Fprintf(&buf, "type %s struct {\n\tc rpc.Caller\n}\n\n", client)
+	fmt.Fprintf(&buf, "// New%s returns a client calling over c, such as an rpc.Client or an xclient.XClient.\n", client)
+	fmt.Fprintf(&buf, "func New%s(c rpc.Caller) *%s {\n\treturn &%s{c: c}\n}\n", client, client, client)
+	for _, m := range methods {
+		fmt.Fprintf(&buf, "\nfunc (c *%s) %s(ctx context.Context, args %s) (%s, error) {\n", client, m.Name, m.ArgType, m.ReplyType)
+		fmt.Fprintf(&buf, "\tvar reply %s\n", m.ReplyType)
+		fmt.Fprintf(&buf, "\terr := c.c.Call(ctx, %q, args, &reply)\n", service+"."+m.Name)
+		fmt.Fprintf(&buf, "\treturn reply, err\n}\n")
+	}
+
+	fmt.Fprintf(&buf, "\n// %s adapts an implementation of %s to Server.Register, the service is named %q.\n", service, typeName, service)
+	fmt.Fprintf(&buf, "type %s struct {\n\timpl %s\n}\n", service, typeName)
+	for _, m := range methods {
+		fmt.Fprintf(&buf, "\nfunc (s *%s) %s(args %s, reply *%s) error {\n", service, m.Name, m.ArgType, m.ReplyType)
+		fmt.Fprintf(&buf, "\tr, err := s.impl.%s(args)\n\tif err != nil {\n\t\treturn err\n\t}\n", m.Name)
+		fmt.Fprintf(&buf, "\t*reply = r\n\treturn nil\n}\n")
+	}
+
+	fmt.Fprintf(&buf, "\n// Register%s registers impl on server as the %s service.\n", typeName, service)
+	fmt.Fprintf(&buf, "func Register%s(server *rpc.Server, impl %s) error {\n", typeName, typeName)
+	fmt.Fprintf(&buf, "\treturn server.Register(&%s{impl: impl})\n}\n", service)
+
+	src, err := format.Source(buf.Bytes())
+	if err != nil {
+		return nil, fmt.Errorf("format generated source: %w", err)
+	}
+	return src, nil
+}
+
+func findInterface(f *ast.File, typeName string) (*ast.InterfaceType, error) {
+	for _, decl := range f.Decls {
+		gen, ok := decl.(*ast.GenDecl)
+		if !ok || gen.Tok != token.TYPE {
+			continue
+		}
+		for _, spec := range gen.Specs {
+			ts := spec.(*ast.TypeSpec)
+			if ts.Name.Name != typeName {
+				continue
+			}
+			iface, ok := ts.Type.(*ast.InterfaceType)
+			if !ok {
+				return nil, fmt.Errorf("%s is not an interface", typeName)
+			}
+			return iface, nil
+		}
+	}
+	return nil, fmt.Errorf("can't find interface %s", typeName)
+}
+
+// fieldCount returns the number of parameters or results in fields.
+func fieldCount(fields *ast.FieldList) int {
+	if fields == nil {
+		return 0
+	}
+	n := 0
+	for _, field := range fields.List {
+		if len(field.Names) == 0 {
+			n++
+		} else {
+			n += len(field.Names)
+		}
+	}
+	return n
+}
+
+// isExportedOrBuiltinType reports whether Server.Register accepts expr as an argument or reply type,
+// as isExportedOrBuiltinType of the rpc package does.
+func isExportedOrBuiltinType(expr ast.Expr) bool {
+	ident, ok := expr.(*ast.Ident)
+	if !ok {
+		return true // a type of another package, which is exported, or an unnamed type
+	}
+	_, builtin := types.Universe.Lookup(ident.Name).(*types.TypeName)
+	return ast.IsExported(ident.Name) || builtin
+}
+
+// collectPackages adds the names of the packages referred to in expr to used.
+func collectPackages(expr ast.Expr, used map[string]bool) {
+	ast.Inspect(expr, func(n ast.Node) bool {
+		if sel, ok := n.(*ast.SelectorExpr); ok {
+			if ident, ok := sel.X.(*ast.Ident); ok {
+				used[ident.Name] = true
+			}
+		}
+		return true
+	})
+}
+
+// imports returns the import specs of f for the used packages, sorted. dir is the directory of f.
+func imports(f *ast.File, dir string, used map[string]bool) ([]string, error) {
+	var specs []string
+	for _, imp := range f.Imports {
+		path, _ := strconv.Unquote(imp.Path.Value)
+		if path == rpcPath || path == "context" {
+			continue
+		}
+		var name string
+		if imp.Name != nil {
+			name = imp.Name.Name
+		} else {
+			// the package name may differ from the last element of the path, as in "example.com/foo/v2"
+			ctxt := build.Default
+			ctxt.Dir = dir // to find the module of f
+			pkg, err := ctxt.Import(path, dir, 0)
+			if err != nil {
+				return nil, fmt.Errorf("can't find the package name of %s: %w", path, err)
+			}
+			name = pkg.Name
+		}
+		if !used[name] {
+			continue
+		}
+		if imp.Name != nil {
+			specs = append(specs, imp.Name.Name+" "+imp.Path.Value)
+		} else {
+			specs = append(specs, imp.Path.Value)
+		}
+	}
+	sort.Strings(specs)
+	return specs, nil
+}
+
+func exprString(fset *token.FileSet, expr ast.Expr) string {
+	var buf bytes.Buffer
+	_ = printer.Fprint(&buf, fset, expr)
+	return buf.String()
+}
@@ -0,0 +1,51 @@
+package main
+
+import (
+	"bytes"
+	"github.com/GallifreyGoTutoural/ggt-rpc/internal/testutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestGenerate(t *testing.T) {
+	src, err := generate("example/arith.go", "Arith")
+	testutil.Assert(err == nil, "generate() error:%v", err)
+	committed, err := os.ReadFile("example/arith_ggtrpc.go")
+	testutil.Assert(err == nil, "read generated file error:%v", err)
+	testutil.Assert(bytes.Equal(src, committed), "example/arith_ggtrpc.go is out of date, run go generate")
+
+	t.Run("invalid interfaces", func(t *testing.T) {
+		for decl, msg := range map[string]string{
+			"type I interface{ M(a, b int) (int, error) }":        "one argument",
+			"type I interface{ M(a int) error }":                  "one argument",
+			"type I interface{ M(a int) (int, string) }":          "error last",
+			"type I interface{ M(a ...int) (int, error) }":        "variadic",
+			"type I interface{ fmt.Stringer }":                    "embedded",
+			"type I struct{}":                                     "not an interface",
+			"type J interface{ M(a int) (int, error) }":           "can't find",
+			"type I interface{ M(a t) (int, error) }; type t int": "exported",
+			"type I interface{ M(a int) (t, error) }; type t int": "exported",
+		} {
+			file := filepath.Join(t.TempDir(), "i.go")
+			_ = os.WriteFile(file, []byte("package p\n\n"+decl+"\n"), 0644)
+			_, err := generate(file, "I")
+			testutil.Assert(err != nil && strings.Contains(err.Error(), msg), "%s: expect error %q, but got %v", decl, msg, err)
+		}
+	})
+
+	t.Run("major version import", func(t *testing.T) {
+		dir := t.TempDir()
+		for file, content := range map[string]string{
+			"go.mod":        "module example.com/m\n\ngo 1.20\n",
+			"lib/v2/lib.go": "package lib\n\ntype Args struct{}\n",
+			"i.go":          "package p\n\nimport \"example.com/m/lib/v2\"\n\ntype I interface{ M(a lib.Args) (int, error) }\n",
+		} {
+			_ = os.MkdirAll(filepath.Dir(filepath.Join(dir, file)), 0755)
+			_ = os.WriteFile(filepath.Join(dir, file), []byte(content), 0644)
+		}
+		src, err := generate(filepath.Join(dir, "i.go"), "I")
+		testutil.Assert(err == nil && bytes.Contains(src, []byte(`"example.com/m/lib/v2"`)), "generate() error:%v source:\n%s", err, src)
+	})
+}
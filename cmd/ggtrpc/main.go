@@ -0,0 +1,225 @@
+// Command ggtrpc invokes the methods of ggt-rpc servers with JSON arguments,
+// using the Reflection service of the server to build the arguments and decode the replies.
+//
+// Usage:
+//
+//	ggtrpc -addr tcp@localhost:9999 list
+//	ggtrpc -addr tcp@localhost:9999 describe Foo
+//	ggtrpc -registry http://localhost:9999/_ggt-rpc_/ggt-registry call Foo.Sum '{"Num1": 1, "Num2": 2}'
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	. "github.com/GallifreyGoTutoural/ggt-rpc/rpc"
+	"github.com/GallifreyGoTutoural/ggt-rpc/xclient"
+	"io"
+	"os"
+	"reflect"
+	"strings"
+	"time"
+)
+
+func main() {
+	if err := run(os.Args[1:], os.Stdout); err != nil {
+		fmt.Fprintln(os.Stderr, "ggtrpc:", err)
+		os.Exit(1)
+	}
+}
+
+func run(args []string, stdout io.Writer) error {
+	flags := flag.NewFlagSet("ggtrpc", flag.ContinueOnError)
+	addr := flags.String("addr", "", "address of the server, as XDial takes it, e.g. tcp@localhost:9999")
+	registry := flags.String("registry", "", "URL of the registry, e.g. http://localhost:9999/_ggt-rpc_/ggt-registry")
+	timeout := flags.Duration("timeout", 5*time.Second, "timeout of the command")
+	flags.Usage = func() {
+		fmt.Fprintln(flags.Output(), "usage: ggtrpc (-addr address | -registry url) list | describe Service | call Service.Method [json]")
+		flags.PrintDefaults()
+	}
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+	var d xclient.Discovery
+	switch {
+	case *addr != "" && *registry == "":
+		d = xclient.NewMultiServerDiscovery([]string{*addr})
+	case *registry != "" && *addr == "":
+		d = xclient.NewGGTRegistryDiscovery(*registry, 0)
+	default:
+		flags.Usage()
+		return errors.New("one of -addr and -registry is required")
+	}
+	xc := xclient.NewXClient(d, xclient.RandomSelect, nil)
+	defer func() { _ = xc.Close() }()
+	ctx, cancel := context.WithTimeout(context.Background(), *timeout)
+	defer cancel()
+
+	cmd := flags.Args()
+	switch {
+	case len(cmd) == 1 && cmd[0] == "list":
+		var names []string
+		if err := xc.Call(ctx, "Reflection.ListServices", "", &names); err != nil {
+			return err
+		}
+		for _, name := range names {
+			fmt.Fprintln(stdout, name)
+		}
+		return nil
+	case len(cmd) == 2 && cmd[0] == "describe":
+		var desc ServiceDescriptor
+		if err := xc.Call(ctx, "Reflection.DescribeService", cmd[1], &desc); err != nil {
+			return err
+		}
+		for _, m := range desc.Methods {
+			fmt.Fprintf(stdout, "%s.%s(%s, %s) error\n", desc.Name, m.Name, typeString(m.ArgType), typeString(m.ReplyType))
+		}
+		return nil
+	case (len(cmd) == 2 || len(cmd) == 3) && cmd[0] == "call":
+		params := "null"
+		if len(cmd) == 3 {
+			params = cmd[2]
+		}
+		reply, err := call(ctx, xc, cmd[1], params)
+		if err != nil {
+			return err
+		}
+		out, err := json.MarshalIndent(reply, "", "  ")
+		if err != nil {
+			return err
+		}
+		fmt.Fprintln(stdout, string(out))
+		return nil
+	default:
+		flags.Usage()
+		return errors.New("unknown command: " + strings.Join(cmd, " "))
+	}
+}
+
+// call invokes serviceMethod with the JSON params, and returns the reply.
+func call(ctx context.Context, xc *xclient.XClient, serviceMethod, params string) (interface{}, error) {
+	dot := strings.LastIndex(serviceMethod, ".")
+	if dot < 0 {
+		return nil, errors.New("service/method ill-formed: " + serviceMethod)
+	}
+	var desc ServiceDescriptor
+	if err := xc.Call(ctx, "Reflection.DescribeService", serviceMethod[:dot], &desc); err != nil {
+		return nil, err
+	}
+	var method *MethodDescriptor
+	for i := range desc.Methods {
+		if desc.Methods[i].Name == serviceMethod[dot+1:] {
+			method = &desc.Methods[i]
+		}
+	}
+	if method == nil {
+		return nil, errors.New("can't find method " + serviceMethod)
+	}
+	argType, err := buildType(method.ArgType)
+	if err != nil {
+		return nil, fmt.Errorf("argument type: %w", err)
+	}
+	replyType, err := buildType(method.ReplyType)
+	if err != nil {
+		return nil, fmt.Errorf("reply type: %w", err)
+	}
+	argv := reflect.New(argType)
+	if err := json.Unmarshal([]byte(params), argv.Interface()); err != nil {
+		return nil, fmt.Errorf("invalid arguments: %w", err)
+	}
+	// the reply type of a method is a pointer
+	replyv := reflect.New(replyType.Elem())
+	if err := xc.Call(ctx, serviceMethod, argv.Elem().Interface(), replyv.Interface()); err != nil {
+		return nil, err
+	}
+	return replyv.Interface(), nil
+}
+
+var basicTypes = map[string]reflect.Type{
+	"bool":       reflect.TypeOf(false),
+	"int":        reflect.TypeOf(int(0)),
+	"int8":       reflect.TypeOf(int8(0)),
+	"int16":      reflect.TypeOf(int16(0)),
+	"int32":      reflect.TypeOf(int32(0)),
+	"int64":      reflect.TypeOf(int64(0)),
+	"uint":       reflect.TypeOf(uint(0)),
+	"uint8":      reflect.TypeOf(uint8(0)),
+	"uint16":     reflect.TypeOf(uint16(0)),
+	"uint32":     reflect.TypeOf(uint32(0)),
+	"uint64":     reflect.TypeOf(uint64(0)),
+	"uintptr":    reflect.TypeOf(uintptr(0)),
+	"float32":    reflect.TypeOf(float32(0)),
+	"float64":    reflect.TypeOf(float64(0)),
+	"complex64":  reflect.TypeOf(complex64(0)),
+	"complex128": reflect.TypeOf(complex128(0)),
+	"string":     reflect.TypeOf(""),
+}
+
+// buildType builds a type with the same structure as d, which gob and json handle like the described one.
+func buildType(d *TypeDescriptor) (reflect.Type, error) {
+	if d.Recursive {
+		return nil, errors.New("recursive type not supported: " + d.Name)
+	}
+	if t, ok := basicTypes[d.Kind]; ok {
+		return t, nil
+	}
+	switch d.Kind {
+	case "ptr", "slice", "array", "map":
+		elem, err := buildType(d.Elem)
+		if err != nil {
+			return nil, err
+		}
+		switch d.Kind {
+		case "ptr":
+			return reflect.PointerTo(elem), nil
+		case "slice":
+			return reflect.SliceOf(elem), nil
+		case "array":
+			return reflect.ArrayOf(d.Len, elem), nil
+		}
+		key, err := buildType(d.Key)
+		if err != nil {
+			return nil, err
+		}
+		return reflect.MapOf(key, elem), nil
+	case "struct":
+		fields := make([]reflect.StructField, 0, len(d.Fields))
+		for _, f := range d.Fields {
+			t, err := buildType(f.Type)
+			if err != nil {
+				return nil, err
+			}
+			fields = append(fields, reflect.StructField{Name: f.Name, Type: t, Tag: reflect.StructTag(f.Tag)})
+		}
+		return reflect.StructOf(fields), nil
+	default:
+		return nil, errors.New("kind not supported: " + d.Kind)
+	}
+}
+
+// typeString formats d like Go source.
+func typeString(d *TypeDescriptor) string {
+	if d.Name != "" {
+		return d.Name
+	}
+	switch d.Kind {
+	case "ptr":
+		return "*" + typeString(d.Elem)
+	case "slice":
+		return "[]" + typeString(d.Elem)
+	case "array":
+		return fmt.Sprintf("[%d]%s", d.Len, typeString(d.Elem))
+	case "map":
+		return "map[" + typeString(d.Key) + "]" + typeString(d.Elem)
+	case "struct":
+		fields := make([]string, 0, len(d.Fields))
+		for _, f := range d.Fields {
+			fields = append(fields, f.Name+" "+typeString(f.Type))
+		}
+		return "struct{" + strings.Join(fields, "; ") + "}"
+	default:
+		return d.Kind
+	}
+}
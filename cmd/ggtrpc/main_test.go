@@ -0,0 +1,72 @@
+package main
+
+import (
+	"bytes"
+	"github.com/GallifreyGoTutoural/ggt-rpc/internal/testutil"
+	. "github.com/GallifreyGoTutoural/ggt-rpc/rpc"
+	"net"
+	"strings"
+	"testing"
+)
+
+type Store struct{}
+
+type Item struct {
+	Key  string            `json:"key"`
+	Tags map[string]string `json:"tags"`
+}
+
+func (s *Store) Keys(items []Item, reply *[]string) error {
+	for _, item := range items {
+		*reply = append(*reply, item.Key+"="+item.Tags["k"])
+	}
+	return nil
+}
+
+type Empty struct{}
+
+func (s *Store) Ping(_ Empty, reply *string) error {
+	*reply = "pong"
+	return nil
+}
+
+type Tree map[string]Tree
+
+func (s *Store) Size(tree Tree, reply *int) error {
+	*reply = len(tree)
+	return nil
+}
+
+func TestRun(t *testing.T) {
+	var foo Foo
+	server := NewServer()
+	_ = server.Register(&foo)
+	_ = server.Register(&Store{})
+	_ = server.RegisterReflection()
+	l, _ := net.Listen("tcp", "127.0.0.1:0")
+	defer l.Close()
+	go server.Accept(l)
+	addr := "tcp@" + l.Addr().String()
+	ggtrpc := func(args ...string) (string, error) {
+		var out bytes.Buffer
+		err := run(append([]string{"-addr", addr}, args...), &out)
+		return strings.TrimSpace(out.String()), err
+	}
+
+	out, err := ggtrpc("list")
+	testutil.Assert(err == nil && out == "Foo\nReflection\nStore", "list error:%v output:%q", err, out)
+	out, err = ggtrpc("describe", "Foo")
+	testutil.Assert(err == nil && strings.Contains(out, "Foo.Sum(rpc.Args, *int) error"), "describe error:%v output:%q", err, out)
+	out, err = ggtrpc("call", "Foo.Sum", `{"Num1": 1, "Num2": 2}`)
+	testutil.Assert(err == nil && out == "3", "call error:%v output:%q", err, out)
+	out, err = ggtrpc("call", "Store.Keys", `[{"key": "a", "tags": {"k": "v"}}]`)
+	testutil.Assert(err == nil && out == "[\n  \"a=v\"\n]", "call error:%v output:%q", err, out)
+	out, err = ggtrpc("call", "Store.Ping", `{}`)
+	testutil.Assert(err == nil && out == `"pong"`, "call error:%v output:%q", err, out)
+	_, err = ggtrpc("call", "Foo.Sum", `{"Num1": "x"}`)
+	testutil.Assert(err != nil, "expect an error for invalid arguments")
+	_, err = ggtrpc("call", "Store.Size", `{}`)
+	testutil.Assert(err != nil && strings.Contains(err.Error(), "recursive"), "expect an error for a recursive type, but got %v", err)
+	_, err = ggtrpc("call", "Foo.Nope")
+	testutil.Assert(err != nil, "expect an error for an unknown method")
+}
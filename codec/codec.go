@@ -1,11 +1,15 @@
 package codec
 
-import "io"
+import (
+	"errors"
+	"io"
+)
 
 type Header struct {
-	ServiceMethod string // format "Service.Method"
-	Seq           uint64 // sequence number chosen by client
-	Error         string // error status, if any
+	ServiceMethod string            // format "Service.Method"
+	Seq           uint64            // sequence number chosen by client
+	Error         string            // error status, if any
+	Metadata      map[string]string // request metadata, such as the version constraint
 }
 
 type Codec interface {
@@ -15,6 +19,16 @@ type Codec interface {
 	Write(*Header, interface{}) error
 }
 
+// ErrMessageTooLarge is returned by codecs reading a message larger than the limit set by SetMaxSize.
+// The message has been skipped without being read into memory.
+var ErrMessageTooLarge = errors.New("rpc codec: message too large")
+
+// SizeLimiter is implemented by codecs that can reject oversize messages.
+type SizeLimiter interface {
+	// SetMaxSize sets the max size in bytes of headers and bodies to read, 0 means no limit.
+	SetMaxSize(header, body int)
+}
+
 type NewCodecFunc func(closer io.ReadWriteCloser) Codec
 
 type Type string
@@ -3,6 +3,7 @@ package codec
 import (
 	"bufio"
 	"encoding/gob"
+	"errors"
 	"io"
 	"log"
 )
@@ -10,18 +11,22 @@ import (
 type GobCodec struct {
 	conn io.ReadWriteCloser // Connection to client
 	buf  *bufio.Writer      // Buffered writer for writing to conn
+	r    *gobMessageReader  // Reader of gob messages from conn
 	dec  *gob.Decoder       // For reading header & body
 	enc  *gob.Encoder       // For writing header & body
 }
 
 var _ Codec = (*GobCodec)(nil)
+var _ SizeLimiter = (*GobCodec)(nil)
 
 func NewGobCodec(conn io.ReadWriteCloser) Codec {
 	buf := bufio.NewWriter(conn)
+	r := &gobMessageReader{r: bufio.NewReader(conn)}
 	return &GobCodec{
 		conn: conn,
 		buf:  buf,
-		dec:  gob.NewDecoder(conn),
+		r:    r,
+		dec:  gob.NewDecoder(r),
 		enc:  gob.NewEncoder(buf),
 	}
 }
@@ -30,12 +35,29 @@ func (g GobCodec) Close() error {
 	return g.conn.Close()
 }
 
+func (g GobCodec) SetMaxSize(header, body int) {
+	g.r.maxHeader, g.r.maxBody = header, body
+}
+
 func (g GobCodec) ReadHeader(header *Header) error {
-	return g.dec.Decode(header)
+	g.r.max = g.r.maxHeader
+	return g.decode(header)
 }
 
 func (g GobCodec) ReadBody(body interface{}) error {
-	return g.dec.Decode(body)
+	g.r.max = g.r.maxBody
+	return g.decode(body)
+}
+
+func (g GobCodec) decode(e interface{}) error {
+	err := g.dec.Decode(e)
+	if errors.Is(err, ErrMessageTooLarge) {
+		if discardErr := g.r.discard(); discardErr != nil {
+			return discardErr
+		}
+		return ErrMessageTooLarge
+	}
+	return err
 }
 
 func (g GobCodec) Write(header *Header, body interface{}) (err error) {
@@ -56,3 +78,112 @@ func (g GobCodec) Write(header *Header, body interface{}) (err error) {
 	return nil
 
 }
+
+// gobMessageReader reads a gob stream message by message, rejecting messages larger than max by their length prefix.
+// It never reads beyond the current message, so that the limit can change between messages.
+type gobMessageReader struct {
+	r                  *bufio.Reader
+	max                int // max size of the next message, 0 means no limit
+	maxHeader, maxBody int
+	remain             int // bytes remaining in the current message, including its length prefix
+	rejected           int // bytes of the rejected message, including its length prefix
+}
+
+const (
+	gobUintMaxLen     = 9       // max length of an encoded gob unsigned integer
+	gobMaxMessageSize = 1 << 30 // gob refuses messages from this size on anyway
+	gobMaxTypeSize    = 64 << 10
+)
+
+func (m *gobMessageReader) Read(p []byte) (int, error) {
+	if m.remain == 0 {
+		if err := m.next(); err != nil {
+			return 0, err
+		}
+	}
+	if len(p) > m.remain {
+		p = p[:m.remain]
+	}
+	n, err := m.r.Read(p)
+	m.remain -= n
+	return n, err
+}
+
+func (m *gobMessageReader) ReadByte() (byte, error) {
+	var b [1]byte
+	if _, err := io.ReadFull(m, b[:]); err != nil {
+		return 0, err
+	}
+	return b[0], nil
+}
+
+// next peeks the length prefix of the next message.
+func (m *gobMessageReader) next() error {
+	prefix, err := m.r.Peek(1)
+	if err != nil {
+		return err
+	}
+	width, size := 1, uint64(prefix[0])
+	if prefix[0] > 0x7f {
+		width = 1 + int(-int8(prefix[0]))
+		if width > gobUintMaxLen {
+			return errInvalidGobLength
+		}
+		if prefix, err = m.r.Peek(width); err != nil {
+			return err
+		}
+		size = 0
+		for _, b := range prefix[1:] {
+			size = size<<8 | uint64(b)
+		}
+	}
+	if size >= gobMaxMessageSize {
+		return errInvalidGobLength
+	}
+	max := m.max
+	if max > 0 && max < gobMaxTypeSize && size > uint64(max) {
+		typeDef, err := m.isTypeDef(width, size)
+		if err != nil {
+			return err
+		}
+		if typeDef {
+			max = gobMaxTypeSize
+		}
+	}
+	if max > 0 && size > uint64(max) {
+		m.rejected = width + int(size)
+		return ErrMessageTooLarge
+	}
+	m.remain = width + int(size)
+	return nil
+}
+
+// isTypeDef peeks the type id after the length prefix of width bytes, which is negative for a type definition.
+func (m *gobMessageReader) isTypeDef(width int, size uint64) (bool, error) {
+	id, err := m.r.Peek(width + 1)
+	if err != nil {
+		return false, err
+	}
+	n := 1
+	if id[width] > 0x7f {
+		n = 1 + int(-int8(id[width]))
+		if n > gobUintMaxLen || uint64(n) > size {
+			return false, errInvalidGobLength
+		}
+		if id, err = m.r.Peek(width + n); err != nil {
+			return false, err
+		}
+	}
+	// gob encodes the sign of an int in the lowest bit
+	return id[width+n-1]&1 == 1, nil
+}
+
+var errInvalidGobLength = errors.New("rpc codec: invalid gob message length")
+
+// discard skips the rejected message.
+func (m *gobMessageReader) discard() error {
+	n := m.rejected
+	m.rejected = 0
+	_, err := m.r.Discard(n)
+	return err
+}
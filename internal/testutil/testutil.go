@@ -0,0 +1,11 @@
+// Package testutil holds the helpers shared by the tests of the packages.
+package testutil
+
+import "fmt"
+
+// Assert panics with the formatted msg if cond is false, like _assert in the tests of the rpc package.
+func Assert(cond bool, msg string, v ...interface{}) {
+	if !cond {
+		panic(fmt.Sprintf("assertion failed: "+msg, v...))
+	}
+}
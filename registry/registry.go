@@ -1,8 +1,10 @@
 package registry
 
 import (
+	"github.com/GallifreyGoTutoural/ggt-rpc/version"
 	"log"
 	"net/http"
+	"net/url"
 	"sort"
 	"strings"
 	"sync"
@@ -18,6 +20,7 @@ type GGTRegistry struct {
 
 type ServerItem struct {
 	Addr      string
+	Metadata  string // url-encoded metadata of the server, e.g. "weight=2"
 	startTime time.Time
 }
 
@@ -35,20 +38,28 @@ func New(timeout time.Duration) *GGTRegistry {
 
 var DefaultGGTRegistry = New(defaultTimeout)
 
-func (r *GGTRegistry) putServer(addr string) {
+func (r *GGTRegistry) putServer(addr, meta string) {
 	r.mu.Lock()
 	defer r.mu.Unlock()
 	s := r.servers[addr]
 	if s == nil {
 		r.servers[addr] = &ServerItem{
 			Addr:      addr,
+			Metadata:  meta,
 			startTime: time.Now(),
 		}
 	} else {
+		s.Metadata = meta
 		s.startTime = time.Now()
 	}
 }
 
+func (r *GGTRegistry) removeServer(addr string) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	delete(r.servers, addr)
+}
+
 func (r *GGTRegistry) aliveServers() []string {
 	r.mu.Lock()
 	defer r.mu.Unlock()
@@ -64,46 +75,152 @@ func (r *GGTRegistry) aliveServers() []string {
 	return alive
 }
 
-func sendHeartbeat(registry, addr string) error {
+// metadataOf returns the metadata of the servers, formatted as "addr metadata", servers without metadata are left out.
+func (r *GGTRegistry) metadataOf(servers []string) []string {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	var metadata []string
+	for _, addr := range servers {
+		if s, ok := r.servers[addr]; ok && s.Metadata != "" {
+			metadata = append(metadata, addr+" "+s.Metadata)
+		}
+	}
+	return metadata
+}
+
+// compatibleServers returns the servers advertising a version of service matching the version constraint,
+// or no version of service at all, which check the constraint themselves.
+func (r *GGTRegistry) compatibleServers(servers []string, service, constraint string) []string {
+	if constraint == "" {
+		return servers
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	var compatible []string
+	for _, addr := range servers {
+		s, ok := r.servers[addr]
+		if !ok {
+			continue
+		}
+		meta, err := url.ParseQuery(s.Metadata)
+		if err == nil && (!meta.Has(version.Key(service)) || version.AnyMatches(meta.Get(version.Key(service)), constraint)) {
+			compatible = append(compatible, addr)
+		}
+	}
+	return compatible
+}
+
+func sendHeartbeat(registry, addr string, meta map[string]string) error {
 	log.Println(addr, "send heart beat to registry", registry)
+	return sendServer("POST", registry, addr, meta)
+}
+
+// sendDown tells the registry to remove the server at once.
+func sendDown(registry, addr string) error {
+	log.Println(addr, "is not serving, remove it from registry", registry)
+	return sendServer("DELETE", registry, addr, nil)
+}
+
+func sendServer(method, registry, addr string, meta map[string]string) error {
 	httpClient := &http.Client{}
-	req, _ := http.NewRequest("POST", registry, nil)
+	req, _ := http.NewRequest(method, registry, nil)
 	req.Header.Set("X-GGT-RPC-SERVER", addr)
-	if _, err := httpClient.Do(req); err != nil {
+	if len(meta) > 0 {
+		values := make(url.Values, len(meta))
+		for k, v := range meta {
+			values.Set(k, v)
+		}
+		req.Header.Set("X-GGT-RPC-SERVER-META", values.Encode())
+	}
+	resp, err := httpClient.Do(req)
+	if err != nil {
 		log.Println("rpc server: heart beat err:", err)
 		return err
 	}
+	_ = resp.Body.Close()
 	return nil
-
 }
 
 func Heartbeat(registry, addr string, duration time.Duration) {
+	HeartbeatWithMetadata(registry, addr, duration, nil)
+}
+
+// HeartbeatWithMetadata is like Heartbeat, and publishes the metadata of the server with every heartbeat,
+// e.g. {"weight": "2"} for weighted round-robin selection.
+func HeartbeatWithMetadata(registry, addr string, duration time.Duration, meta map[string]string) {
 	if duration == 0 {
 		duration = defaultTimeout - time.Duration(1)*time.Minute
 	}
 	var err error
-	err = sendHeartbeat(registry, addr)
+	err = sendHeartbeat(registry, addr, meta)
 	go func() {
 		t := time.NewTicker(duration)
 		for err == nil {
 			<-t.C
-			err = sendHeartbeat(registry, addr)
+			err = sendHeartbeat(registry, addr, meta)
 		}
 	}()
 
 }
 
+// HeartbeatWhileServing is like HeartbeatWithMetadata, and removes the server from the registry
+// while serving, such as rpc.Health.Serving, returns false.
+func HeartbeatWhileServing(registry, addr string, duration time.Duration, meta map[string]string, serving func() bool) {
+	if duration == 0 {
+		duration = defaultTimeout - time.Duration(1)*time.Minute
+	}
+	interval := duration
+	if interval > time.Second {
+		interval = time.Second
+	}
+	var err error
+	up := serving()
+	if up {
+		err = sendHeartbeat(registry, addr, meta)
+	}
+	go func() {
+		t := time.NewTicker(interval)
+		defer t.Stop()
+		last := time.Now()
+		for err == nil {
+			<-t.C
+			wasUp := up
+			up = serving()
+			switch {
+			case up && (!wasUp || time.Since(last) >= duration):
+				err = sendHeartbeat(registry, addr, meta)
+				last = time.Now()
+			case !up && wasUp:
+				err = sendDown(registry, addr)
+			}
+		}
+	}()
+}
+
+// ServeHTTP lists the alive servers on GET, only the ones compatible with the version
+// of the query "?service=Foo&version=1", receives heartbeats on POST, and removes a server on DELETE.
 func (r *GGTRegistry) ServeHTTP(w http.ResponseWriter, req *http.Request) {
 	switch req.Method {
 	case "GET":
-		w.Header().Set("X-GGT-RPC-SERVER-LIST", strings.Join(r.aliveServers(), ","))
+		servers := r.compatibleServers(r.aliveServers(), req.URL.Query().Get("service"), req.URL.Query().Get("version"))
+		w.Header().Set("X-GGT-RPC-SERVER-LIST", strings.Join(servers, ","))
+		for _, meta := range r.metadataOf(servers) {
+			w.Header().Add("X-GGT-RPC-SERVER-META", meta)
+		}
 	case "POST":
 		addr := req.Header.Get("X-GGT-RPC-SERVER")
 		if addr == "" {
 			w.WriteHeader(http.StatusInternalServerError)
 			return
 		}
-		r.putServer(addr)
+		r.putServer(addr, req.Header.Get("X-GGT-RPC-SERVER-META"))
+	case "DELETE":
+		addr := req.Header.Get("X-GGT-RPC-SERVER")
+		if addr == "" {
+			w.WriteHeader(http.StatusInternalServerError)
+			return
+		}
+		r.removeServer(addr)
 	default:
 		w.WriteHeader(http.StatusMethodNotAllowed)
 	}
@@ -0,0 +1,74 @@
+package registry
+
+import (
+	"github.com/GallifreyGoTutoural/ggt-rpc/internal/testutil"
+	"github.com/GallifreyGoTutoural/ggt-rpc/version"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+)
+
+// list gets the servers and their metadata from the registry at addr.
+func list(addr string) (string, []string) {
+	resp, err := http.Get(addr)
+	testutil.Assert(err == nil && resp.StatusCode == http.StatusOK, "GET error:%v", err)
+	_ = resp.Body.Close()
+	return resp.Header.Get("X-GGT-RPC-SERVER-LIST"), resp.Header.Values("X-GGT-RPC-SERVER-META")
+}
+
+func TestGGTRegistry(t *testing.T) {
+	ts := httptest.NewServer(New(time.Minute))
+	defer ts.Close()
+	meta := map[string]string{"weight": "2", version.Key("Foo"): "1.2 2.0"}
+	testutil.Assert(sendHeartbeat(ts.URL, "tcp@a", meta) == nil, "sendHeartbeat() failed")
+	testutil.Assert(sendHeartbeat(ts.URL, "tcp@b", nil) == nil, "sendHeartbeat() failed")
+
+	servers, metadata := list(ts.URL)
+	testutil.Assert(servers == "tcp@a,tcp@b", "unexpected servers: %s", servers)
+	testutil.Assert(len(metadata) == 1 && strings.HasPrefix(metadata[0], "tcp@a "), "unexpected metadata: %v", metadata)
+	values, err := url.ParseQuery(strings.TrimPrefix(metadata[0], "tcp@a "))
+	testutil.Assert(err == nil && values.Get("weight") == "2" && values.Get(version.Key("Foo")) == "1.2 2.0",
+		"metadata not round-tripped: %v", values)
+
+	t.Run("version", func(t *testing.T) {
+		_ = sendHeartbeat(ts.URL, "tcp@c", map[string]string{version.Key("Foo"): "1.0"})
+		defer func() { _ = sendDown(ts.URL, "tcp@c") }()
+		for constraint, want := range map[string]string{
+			"":     "tcp@a,tcp@b,tcp@c",
+			"2":    "tcp@a,tcp@b",
+			"<1.2": "tcp@b,tcp@c",
+			"3":    "tcp@b",
+		} {
+			servers, _ := list(ts.URL+"?"+url.Values{"service": {"Foo"}, "version": {constraint}}.Encode())
+			testutil.Assert(servers == want, "constraint %q: expect %s, but got %s", constraint, want, servers)
+		}
+	})
+	t.Run("delete", func(t *testing.T) {
+		testutil.Assert(sendDown(ts.URL, "tcp@b") == nil, "sendDown() failed")
+		servers, _ := list(ts.URL)
+		testutil.Assert(servers == "tcp@a", "expect tcp@b to be removed, but got %s", servers)
+	})
+	t.Run("bad requests", func(t *testing.T) {
+		for _, method := range []string{"POST", "DELETE"} {
+			req, _ := http.NewRequest(method, ts.URL, nil)
+			resp, err := http.DefaultClient.Do(req)
+			testutil.Assert(err == nil && resp.StatusCode == http.StatusInternalServerError, "%s without server: %v", method, err)
+			_ = resp.Body.Close()
+		}
+		req, _ := http.NewRequest("PUT", ts.URL, nil)
+		resp, err := http.DefaultClient.Do(req)
+		testutil.Assert(err == nil && resp.StatusCode == http.StatusMethodNotAllowed, "PUT: %v", err)
+		_ = resp.Body.Close()
+	})
+}
+
+func TestGGTRegistry_Timeout(t *testing.T) {
+	r := New(50 * time.Millisecond)
+	r.putServer("tcp@a", "")
+	testutil.Assert(len(r.aliveServers()) == 1, "expect tcp@a to be alive")
+	time.Sleep(100 * time.Millisecond)
+	testutil.Assert(len(r.aliveServers()) == 0, "expect tcp@a to expire")
+}
@@ -13,17 +13,19 @@ import (
 	"net/http"
 	"strings"
 	"sync"
+	"sync/atomic"
 	"time"
 )
 
 // Call represents an active RPC.
 type Call struct {
-	Seq           uint64      // sequence number chosen by client
-	ServiceMethod string      // format "Service.Method"
-	Args          interface{} // arguments to the function
-	Reply         interface{} // reply from the function
-	Error         error       // if error occurs, it will be set
-	Done          chan *Call  // strobes when call is complete
+	Seq           uint64            // sequence number chosen by client
+	ServiceMethod string            // format "Service.Method"
+	Args          interface{}       // arguments to the function
+	Reply         interface{}       // reply from the function
+	Error         error             // if error occurs, it will be set
+	Done          chan *Call        // strobes when call is complete
+	Metadata      map[string]string // request metadata sent in the header
 }
 
 func (call *Call) done() {
@@ -40,8 +42,11 @@ type Client struct {
 	mu       sync.Mutex // protect following
 	seq      uint64
 	pending  map[uint64]*Call
-	closing  bool // user has called Close
-	shutdown bool // server has told us to stop
+	closing  bool          // user has called Close
+	shutdown bool          // server has told us to stop
+	draining bool          // server has told us to go away, calls already sent are still answered
+	lastRecv int64         // UnixNano time of the last frame received from server
+	done     chan struct{} // closed when the client is shut down
 }
 
 type clientResult struct {
@@ -76,7 +81,7 @@ func dialTimeout(f newClientFunc, network, address string, opts ...*Option) (cli
 	}
 	select {
 	case <-time.After(opt.ConnectionTimeoutSec):
-		return nil, errors.New("rpc client: connect timeout")
+		return nil, ErrConnectTimeout
 	case result := <-ch:
 		return result.client, result.err
 	}
@@ -85,6 +90,25 @@ func dialTimeout(f newClientFunc, network, address string, opts ...*Option) (cli
 
 var ErrShutdown = errors.New("connection is shut down")
 
+// ErrConnectTimeout is returned when the connection is not established within Option.ConnectionTimeoutSec.
+var ErrConnectTimeout = errors.New("rpc client: connect timeout")
+
+// ErrMessageTooLarge is returned when a message exceeds the max header or body size.
+var ErrMessageTooLarge = codec.ErrMessageTooLarge
+
+// serverErrors are errors sent by the server as their message, which the client turns back into the same values.
+var serverErrors = []error{ErrMessageTooLarge}
+
+// serverError returns the error of the message in a response header.
+func serverError(msg string) error {
+	for _, err := range serverErrors {
+		if err.Error() == msg {
+			return err
+		}
+	}
+	return errors.New(msg)
+}
+
 func (client *Client) Close() error {
 	client.mu.Lock()
 	defer client.mu.Unlock()
@@ -98,17 +122,48 @@ func (client *Client) Close() error {
 // insures Client implements io.Closer
 var _ io.Closer = (*Client)(nil)
 
-// IsAvailable returns true if the client does work; in other words, it's not shutdown and not closing.
+// Caller is implemented by the clients that invoke named functions, such as Client, ReconnectingClient
+// and xclient.XClient, so that typed wrappers work over any of them.
+type Caller interface {
+	Call(ctx context.Context, serviceMethod string, args, reply interface{}) error
+}
+
+var _ Caller = (*Client)(nil)
+
+// IsAvailable returns true if the client does work; in other words, it's not shutdown and not closing,
+// and the heartbeat, if enabled, has not timed out.
 func (client *Client) IsAvailable() bool {
 	client.mu.Lock()
 	defer client.mu.Unlock()
-	return !client.shutdown && !client.closing
+	return !client.shutdown && !client.closing && !client.draining && !client.heartbeatTimedOut()
+}
+
+// IsDraining returns true if the server has told the client to go away.
+// No new calls are accepted, and the server closes the connection once the calls already sent are answered.
+func (client *Client) IsDraining() bool {
+	client.mu.Lock()
+	defer client.mu.Unlock()
+	return client.draining && !client.shutdown
+}
+
+// Done returns a channel that is closed when the client is shut down,
+// e.g. once the server has closed the connection of a draining client.
+func (client *Client) Done() <-chan struct{} {
+	return client.done
+}
+
+func (client *Client) heartbeatTimedOut() bool {
+	if client.opt == nil || client.opt.HeartbeatInterval <= 0 {
+		return false
+	}
+	timeout := heartbeatTimeout(client.opt.HeartbeatInterval, client.opt.HeartbeatTimeout)
+	return time.Since(time.Unix(0, atomic.LoadInt64(&client.lastRecv))) > timeout
 }
 
 func (client *Client) registerCall(call *Call) (uint64, error) {
 	client.mu.Lock()
 	defer client.mu.Unlock()
-	if client.closing || client.shutdown {
+	if client.closing || client.shutdown || client.draining {
 		return 0, ErrShutdown
 	}
 	call.Seq = client.seq
@@ -132,6 +187,7 @@ func (client *Client) terminateCalls(err error) {
 	client.mu.Lock()
 	defer client.mu.Unlock()
 	client.shutdown = true
+	close(client.done)
 	for _, call := range client.pending {
 		call.Error = err
 		call.done()
@@ -195,18 +251,28 @@ func NewClient(conn net.Conn, opt *Option) (*Client, error) {
 		_ = conn.Close()
 		return nil, err
 	}
-	return newClientCodec(f(conn)), nil
+	return newClientCodec(f(conn), opt), nil
 }
 
 // newClientCodec returns a ClientCodec with a given codec
-func newClientCodec(cc codec.Codec) *Client {
+func newClientCodec(cc codec.Codec, opt *Option) *Client {
 	client := &Client{
-		cc:      cc,
-		seq:     1,
-		pending: make(map[uint64]*Call),
+		cc:       cc,
+		opt:      opt,
+		seq:      1,
+		pending:  make(map[uint64]*Call),
+		lastRecv: time.Now().UnixNano(),
+		done:     make(chan struct{}),
+	}
+	if limiter, ok := cc.(codec.SizeLimiter); ok {
+		limiter.SetMaxSize(opt.MaxHeaderSize, opt.MaxBodySize)
 	}
 	// start a goroutine to receive response from server
 	go client.receive()
+	if opt.HeartbeatInterval > 0 {
+		timeout := heartbeatTimeout(opt.HeartbeatInterval, opt.HeartbeatTimeout)
+		go heartbeat(cc, &client.sending, &client.lastRecv, opt.HeartbeatInterval, timeout, client.done)
+	}
 	return client
 }
 
@@ -218,13 +284,29 @@ func (client *Client) receive() {
 		if err = client.cc.ReadHeader(&h); err != nil {
 			break
 		}
+		atomic.StoreInt64(&client.lastRecv, time.Now().UnixNano())
+		if isPing(&h) || isPong(&h) {
+			if err = client.cc.ReadBody(nil); err == nil && isPing(&h) {
+				go client.pong(h.Seq)
+			}
+			continue
+		}
+		if isGoAway(&h) {
+			client.mu.Lock()
+			client.draining = true
+			client.mu.Unlock()
+			if err = client.cc.ReadBody(nil); err == nil {
+				go client.goAway()
+			}
+			continue
+		}
 		call := client.removeCall(h.Seq)
 		switch {
 		case call == nil:
 			// it usually means that Write partially failed, we will close the connection
 			err = client.cc.ReadBody(nil)
 		case h.Error != "":
-			call.Error = errors.New(h.Error)
+			call.Error = serverError(h.Error)
 			err = client.cc.ReadBody(nil)
 			call.done()
 		default:
@@ -232,13 +314,34 @@ func (client *Client) receive() {
 			if err != nil {
 				call.Error = errors.New("reading body" + err.Error())
 			}
+			if errors.Is(err, ErrMessageTooLarge) {
+				call.Error = err
+			}
 			call.done()
 		}
+		if errors.Is(err, ErrMessageTooLarge) {
+			// the oversize body has been skipped, so the connection is still usable
+			err = nil
+		}
 	}
 	// error occurs, so terminateCalls pending calls
 	client.terminateCalls(err)
 }
 
+// pong answers the ping with seq from server.
+func (client *Client) pong(seq uint64) {
+	client.sending.Lock()
+	defer client.sending.Unlock()
+	_ = client.cc.Write(&codec.Header{ServiceMethod: pongMethod, Seq: seq}, controlBody)
+}
+
+// goAway answers the GOAWAY from server, after the calls already sent since no new ones are accepted.
+func (client *Client) goAway() {
+	client.sending.Lock()
+	defer client.sending.Unlock()
+	_ = client.cc.Write(&codec.Header{ServiceMethod: goAwayMethod}, controlBody)
+}
+
 func (client *Client) send(call *Call) {
 	// make sure that the client is available
 	client.sending.Lock()
@@ -254,6 +357,7 @@ func (client *Client) send(call *Call) {
 	client.header.ServiceMethod = call.ServiceMethod
 	client.header.Seq = seq
 	client.header.Error = ""
+	client.header.Metadata = call.Metadata
 
 	// encode and send the request
 	if err := client.cc.Write(&client.header, call.Args); err != nil {
@@ -271,6 +375,10 @@ func (client *Client) send(call *Call) {
 // Go invokes the function asynchronously.
 // It returns the Call structure representing the invocation.
 func (client *Client) Go(serviceMethod string, args, reply interface{}, done chan *Call) *Call {
+	return client.goWithMetadata(serviceMethod, args, reply, nil, done)
+}
+
+func (client *Client) goWithMetadata(serviceMethod string, args, reply interface{}, md map[string]string, done chan *Call) *Call {
 	if done == nil {
 		done = make(chan *Call, 10)
 	} else if cap(done) == 0 {
@@ -281,14 +389,16 @@ func (client *Client) Go(serviceMethod string, args, reply interface{}, done cha
 		Args:          args,
 		Reply:         reply,
 		Done:          done,
+		Metadata:      md,
 	}
 	client.send(call)
 	return call
 }
 
 // Call invokes the named function, waits for it to complete, and returns its error status.
+// The request metadata carried by ctx, see WithMetadata, is sent with the request.
 func (client *Client) Call(ctx context.Context, serviceMethod string, args, reply interface{}) error {
-	call := client.Go(serviceMethod, args, reply, make(chan *Call, 1))
+	call := client.goWithMetadata(serviceMethod, args, reply, MetadataFromContext(ctx), make(chan *Call, 1))
 	select {
 	case <-ctx.Done():
 		client.removeCall(call.Seq)
@@ -296,7 +406,17 @@ func (client *Client) Call(ctx context.Context, serviceMethod string, args, repl
 	case call := <-call.Done:
 		return call.Error
 	}
-	return call.Error
+}
+
+// NewNetRPCClient returns a new Client talking to a net/rpc server with the gob codec.
+// The net/rpc protocol has no options, so the codec type and handle timeout of opt are ignored.
+func NewNetRPCClient(conn net.Conn, opt *Option) (*Client, error) {
+	return newClientCodec(codec.NewGobCodec(conn), opt), nil
+}
+
+// DialNetRPC connects to a net/rpc server at the specified network address.
+func DialNetRPC(network, address string, opts ...*Option) (*Client, error) {
+	return dialTimeout(NewNetRPCClient, network, address, opts...)
 }
 
 func NewHTTPClient(conn net.Conn, opt *Option) (*Client, error) {
@@ -3,7 +3,7 @@ package rpc
 import (
 	"context"
 	"net"
-	"os"
+	"path/filepath"
 	"runtime"
 	"strings"
 	"testing"
@@ -48,18 +48,20 @@ func TestClient_Call(t *testing.T) {
 	t.Parallel()
 	addrCh := make(chan string)
 	go starServer(addrCh)
+	addr := <-addrCh
 	time.Sleep(time.Second)
 	// client handle timeout
 	t.Run("client handle timeout", func(t *testing.T) {
-		client, _ := Dial("tcp", <-addrCh)
+		client, _ := Dial("tcp", addr)
 		var reply int
-		ctx, _ := context.WithTimeout(context.Background(), time.Second)
+		ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+		defer cancel()
 		err := client.Call(ctx, "Bar.Timeout", 1, &reply)
 		_assert(err != nil && strings.Contains(err.Error(), ctx.Err().Error()), "client.Call() error:%v", err)
 	})
 	// server handle timeout
 	t.Run("server handle timeout", func(t *testing.T) {
-		client, _ := Dial("tcp", <-addrCh, &Option{
+		client, _ := Dial("tcp", addr, &Option{
 			HandleTimeoutSec: time.Second,
 		})
 		var reply int
@@ -70,19 +72,13 @@ func TestClient_Call(t *testing.T) {
 
 func TestXDial(t *testing.T) {
 	if runtime.GOOS == "linux" {
-		ch := make(chan struct{})
-		addr := "tmp/ggtrpc.sock"
-		go func() {
-			_ = os.Remove(addr)
-			l, err := net.Listen("unix", addr)
-			if err != nil {
-				t.Fatal(err)
-			}
-			ch <- struct{}{}
-			Accept(l)
-		}()
-		<-ch
-		_, err := XDial("unix@" + addr)
+		addr := filepath.Join(t.TempDir(), "ggtrpc.sock")
+		l, err := net.Listen("unix", addr)
+		if err != nil {
+			t.Fatal(err)
+		}
+		go Accept(l)
+		_, err = XDial("unix@" + addr)
 		_assert(err == nil, "XDial() error:%v", err)
 
 	}
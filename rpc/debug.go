@@ -12,7 +12,7 @@ const debugText = `
 	<title>ggtrpc debug</title>
 	{{range .}}
 	<hr>
-	Service {{.Name}}
+	Service {{.Name}}{{with .Version}} version {{.}}{{end}}
 	<hr>
 		<table>
 		<th align=center>Method</th><th align=center> Calls</th>
@@ -34,18 +34,21 @@ type debugHTTP struct {
 }
 
 type debugService struct {
-	Name   string
-	Method map[string]*methodType
+	Name    string
+	Version string
+	Method  map[string]*methodType
 }
 
 func (server debugHTTP) ServeHTTP(w http.ResponseWriter, req *http.Request) {
 	var services []debugService
-	server.serviceMap.Range(func(namei, svci interface{}) bool {
-		svc := svci.(*service)
-		services = append(services, debugService{
-			Name:   svc.name,
-			Method: svc.method,
-		})
+	server.serviceMap.Range(func(namei, vsi interface{}) bool {
+		for _, svc := range vsi.(*serviceVersions).services {
+			services = append(services, debugService{
+				Name:    svc.name,
+				Version: svc.version,
+				Method:  svc.method,
+			})
+		}
 		return true
 	})
 	err := debug.Execute(w, services)
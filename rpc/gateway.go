@@ -0,0 +1,256 @@
+package rpc
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"reflect"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// Route maps HTTP requests to a registered method.
+type Route struct {
+	Method        string // HTTP method, e.g. "POST"
+	Pattern       string // path pattern, segments like "{name}" capture path parameters, e.g. "/v1/foo/{Num1}/sum"
+	ServiceMethod string // format "Service.Method"
+}
+
+type gatewayRoute struct {
+	Route
+	segments []string
+}
+
+// Gateway is an HTTP handler that serves registered methods as REST/JSON endpoints.
+// Arguments are read from the JSON body, and query and path parameters.
+type Gateway struct {
+	server *Server
+	mu     sync.RWMutex
+	routes []*gatewayRoute
+}
+
+// NewGateway returns a new Gateway for server.
+func NewGateway(server *Server) *Gateway {
+	return &Gateway{server: server}
+}
+
+// Handle maps requests with the HTTP method and path pattern to serviceMethod.
+func (g *Gateway) Handle(method, pattern, serviceMethod string) error {
+	return g.AddRoutes(Route{Method: method, Pattern: pattern, ServiceMethod: serviceMethod})
+}
+
+// AddRoutes adds a route table to the gateway.
+func (g *Gateway) AddRoutes(routes ...Route) error {
+	parsed := make([]*gatewayRoute, 0, len(routes))
+	for _, r := range routes {
+		if !strings.HasPrefix(r.Pattern, "/") {
+			return errors.New("rpc gateway: pattern must begin with '/': " + r.Pattern)
+		}
+		if strings.LastIndex(r.ServiceMethod, ".") < 0 {
+			return errors.New("rpc gateway: service/method ill-formed: " + r.ServiceMethod)
+		}
+		r.Method = strings.ToUpper(r.Method)
+		parsed = append(parsed, &gatewayRoute{Route: r, segments: splitPath(r.Pattern)})
+	}
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	g.routes = append(g.routes, parsed...)
+	return nil
+}
+
+func splitPath(path string) []string {
+	return strings.Split(strings.Trim(path, "/"), "/")
+}
+
+// match returns the path parameters if path matches the route.
+func (r *gatewayRoute) match(segments []string) (map[string]string, bool) {
+	if len(segments) != len(r.segments) {
+		return nil, false
+	}
+	params := make(map[string]string)
+	for i, seg := range r.segments {
+		if strings.HasPrefix(seg, "{") && strings.HasSuffix(seg, "}") {
+			params[seg[1:len(seg)-1]] = segments[i]
+			continue
+		}
+		if seg != segments[i] {
+			return nil, false
+		}
+	}
+	return params, true
+}
+
+// find returns the route matching req, or the HTTP methods of the routes matching its path.
+func (g *Gateway) find(req *http.Request) (*gatewayRoute, map[string]string, []string) {
+	g.mu.RLock()
+	defer g.mu.RUnlock()
+	segments := splitPath(req.URL.Path)
+	var allowed []string
+	for _, r := range g.routes {
+		params, ok := r.match(segments)
+		if !ok {
+			continue
+		}
+		if r.Method == req.Method {
+			return r, params, nil
+		}
+		allowed = append(allowed, r.Method)
+	}
+	return nil, nil, allowed
+}
+
+func (g *Gateway) ServeHTTP(w http.ResponseWriter, req *http.Request) {
+	route, params, allowed := g.find(req)
+	if route == nil {
+		if len(allowed) > 0 {
+			w.Header().Set("Allow", strings.Join(allowed, ", "))
+			writeGatewayError(w, http.StatusMethodNotAllowed, "method not allowed")
+			return
+		}
+		writeGatewayError(w, http.StatusNotFound, "no route for "+req.URL.Path)
+		return
+	}
+	svc, mtype, err := g.server.findService(route.ServiceMethod, "")
+	if err != nil {
+		writeGatewayError(w, http.StatusNotFound, err.Error())
+		return
+	}
+	argv := mtype.newArgv()
+	replyv := mtype.newReplyv()
+	req.Body = g.server.limitBody(w, req)
+	if err = decodeGatewayArgv(req, params, argv); err != nil {
+		writeGatewayError(w, bodyErrorStatus(err), "rpc gateway: invalid argument: "+err.Error())
+		return
+	}
+	if err = svc.call(mtype, argv, replyv); err != nil {
+		writeGatewayError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(replyv.Interface())
+}
+
+func writeGatewayError(w http.ResponseWriter, code int, msg string) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(code)
+	_ = json.NewEncoder(w).Encode(map[string]string{"error": msg})
+}
+
+// decodeGatewayArgv builds argv from the JSON body, the query and the path parameters, in that order.
+func decodeGatewayArgv(req *http.Request, params map[string]string, argv reflect.Value) error {
+	body, err := io.ReadAll(req.Body)
+	if err != nil {
+		return err
+	}
+	if body = bytes.TrimSpace(body); len(body) > 0 {
+		if err = json.Unmarshal(body, argvPointer(argv)); err != nil {
+			return err
+		}
+	}
+	v := argv
+	if v.Kind() == reflect.Pointer {
+		v = v.Elem()
+	}
+	if v.Kind() != reflect.Struct {
+		// a non-struct argument can only be given by the single path parameter
+		if len(params) == 1 {
+			for _, value := range params {
+				return setFromString(v, value)
+			}
+		}
+		return nil
+	}
+	for name, values := range req.URL.Query() {
+		if field, ok := fieldByName(v, name); ok {
+			if err = setFromStrings(field, values); err != nil {
+				return fmt.Errorf("query %s: %v", name, err)
+			}
+		}
+	}
+	for name, value := range params {
+		field, ok := fieldByName(v, name)
+		if !ok {
+			return fmt.Errorf("no field for path parameter %s", name)
+		}
+		if err = setFromString(field, value); err != nil {
+			return fmt.Errorf("path %s: %v", name, err)
+		}
+	}
+	return nil
+}
+
+// fieldByName finds the exported field whose json tag or name equals name, case-insensitively.
+func fieldByName(v reflect.Value, name string) (reflect.Value, bool) {
+	t := v.Type()
+	for i := 0; i < t.NumField(); i++ {
+		f := t.Field(i)
+		if !f.IsExported() {
+			continue
+		}
+		tag := strings.Split(f.Tag.Get("json"), ",")[0]
+		if tag == "-" {
+			continue
+		}
+		if strings.EqualFold(tag, name) || strings.EqualFold(f.Name, name) {
+			return v.Field(i), true
+		}
+	}
+	return reflect.Value{}, false
+}
+
+func setFromStrings(v reflect.Value, values []string) error {
+	if v.Kind() != reflect.Slice {
+		return setFromString(v, values[len(values)-1])
+	}
+	slice := reflect.MakeSlice(v.Type(), len(values), len(values))
+	for i, s := range values {
+		if err := setFromString(slice.Index(i), s); err != nil {
+			return err
+		}
+	}
+	v.Set(slice)
+	return nil
+}
+
+func setFromString(v reflect.Value, s string) error {
+	switch v.Kind() {
+	case reflect.Pointer:
+		if v.IsNil() {
+			v.Set(reflect.New(v.Type().Elem()))
+		}
+		return setFromString(v.Elem(), s)
+	case reflect.String:
+		v.SetString(s)
+	case reflect.Bool:
+		b, err := strconv.ParseBool(s)
+		if err != nil {
+			return err
+		}
+		v.SetBool(b)
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		n, err := strconv.ParseInt(s, 10, v.Type().Bits())
+		if err != nil {
+			return err
+		}
+		v.SetInt(n)
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		n, err := strconv.ParseUint(s, 10, v.Type().Bits())
+		if err != nil {
+			return err
+		}
+		v.SetUint(n)
+	case reflect.Float32, reflect.Float64:
+		n, err := strconv.ParseFloat(s, v.Type().Bits())
+		if err != nil {
+			return err
+		}
+		v.SetFloat(n)
+	default:
+		return errors.New("unsupported type " + v.Type().String())
+	}
+	return nil
+}
@@ -0,0 +1,60 @@
+package rpc
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestGateway(t *testing.T) {
+	var foo Foo
+	server := NewServer()
+	_ = server.Register(&foo)
+	gw := NewGateway(server)
+	err := gw.AddRoutes(
+		Route{Method: "POST", Pattern: "/v1/foo/sum", ServiceMethod: "Foo.Sum"},
+		Route{Method: "GET", Pattern: "/v1/foo/{num1}/sum", ServiceMethod: "Foo.Sum"},
+	)
+	_assert(err == nil, "AddRoutes() error:%v", err)
+	mux := http.NewServeMux()
+	mux.Handle("/v1/", gw)
+	ts := httptest.NewServer(mux)
+	defer ts.Close()
+
+	do := func(method, path, body string) (int, string) {
+		req, _ := http.NewRequest(method, ts.URL+path, strings.NewReader(body))
+		resp, err := http.DefaultClient.Do(req)
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer resp.Body.Close()
+		var raw json.RawMessage
+		_ = json.NewDecoder(resp.Body).Decode(&raw)
+		return resp.StatusCode, strings.TrimSpace(string(raw))
+	}
+
+	t.Run("body", func(t *testing.T) {
+		code, reply := do("POST", "/v1/foo/sum", `{"Num1":1,"Num2":2}`)
+		_assert(code == http.StatusOK && reply == "3", "unexpected response: %d %s", code, reply)
+	})
+	t.Run("path and query", func(t *testing.T) {
+		code, reply := do("GET", "/v1/foo/4/sum?num2=5", "")
+		_assert(code == http.StatusOK && reply == "9", "unexpected response: %d %s", code, reply)
+	})
+	t.Run("bad argument", func(t *testing.T) {
+		code, _ := do("GET", "/v1/foo/x/sum", "")
+		_assert(code == http.StatusBadRequest, "expect status 400, but got %d", code)
+	})
+	t.Run("method not allowed", func(t *testing.T) {
+		rec := httptest.NewRecorder()
+		gw.ServeHTTP(rec, httptest.NewRequest("DELETE", "/v1/foo/sum", nil))
+		_assert(rec.Code == http.StatusMethodNotAllowed, "expect status 405, but got %d", rec.Code)
+		_assert(rec.Header().Get("Allow") == "POST", "expect Allow: POST, but got %q", rec.Header().Get("Allow"))
+	})
+	t.Run("not found", func(t *testing.T) {
+		code, _ := do("POST", "/v1/bar", "")
+		_assert(code == http.StatusNotFound, "expect status 404, but got %d", code)
+	})
+}
@@ -0,0 +1,62 @@
+package rpc
+
+import (
+	"context"
+	"errors"
+	"log"
+	"reflect"
+	"strings"
+)
+
+// CallTyped invokes the named function over c, such as a Client or an xclient.XClient,
+// and returns the reply typed.
+func CallTyped[A, R any](ctx context.Context, c Caller, serviceMethod string, args A) (R, error) {
+	var reply R
+	err := c.Call(ctx, serviceMethod, args, &reply)
+	return reply, err
+}
+
+// HandleFunc registers fn as the method serviceMethod, format "Service.Method", without a receiver type.
+// Functions can be added to the same service one by one, but not to a service registered by Register.
+// The service is unversioned.
+func HandleFunc[A, R any](server *Server, serviceMethod string, fn func(A) (R, error)) error {
+	dot := strings.LastIndex(serviceMethod, ".")
+	if dot <= 0 || dot == len(serviceMethod)-1 {
+		return errors.New("rpc: service/method ill-formed: " + serviceMethod)
+	}
+	if fn == nil {
+		return errors.New("rpc: nil function for " + serviceMethod)
+	}
+	serviceName, methodName := serviceMethod[:dot], serviceMethod[dot+1:]
+	argType, replyType := reflect.TypeOf((*A)(nil)).Elem(), reflect.TypeOf((*R)(nil))
+	if !isExportedOrBuiltinType(argType) || !isExportedOrBuiltinType(replyType.Elem()) {
+		return errors.New("rpc: argument and reply types of " + serviceMethod + " must be exported or builtin")
+	}
+	m := &methodType{fn: reflect.ValueOf(fn), ArgType: argType, ReplyType: replyType}
+	// services are read without locks, so add the method to a copy of the service and swap them
+	for {
+		s := &service{name: serviceName, method: map[string]*methodType{methodName: m}, calls: new(inflight)}
+		old, loaded := server.serviceMap.LoadOrStore(serviceName, &serviceVersions{services: []*service{s}})
+		if !loaded {
+			break
+		}
+		vs := old.(*serviceVersions)
+		if oldSvc := vs.get(""); oldSvc != nil {
+			if oldSvc.rcvr.IsValid() {
+				return errors.New("rpc: service already defined: " + serviceName)
+			}
+			if _, dup := oldSvc.method[methodName]; dup {
+				return errors.New("rpc: method already defined: " + serviceMethod)
+			}
+			for name, mType := range oldSvc.method {
+				s.method[name] = mType
+			}
+			s.calls = oldSvc.calls
+		}
+		if server.serviceMap.CompareAndSwap(serviceName, old, vs.with(s)) {
+			break
+		}
+	}
+	log.Printf("rpc server: register %s\n", serviceMethod)
+	return nil
+}
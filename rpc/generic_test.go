@@ -0,0 +1,48 @@
+package rpc
+
+import (
+	"context"
+	"errors"
+	"net"
+	"strings"
+	"testing"
+)
+
+func TestHandleFunc(t *testing.T) {
+	var foo Foo
+	server := NewServer()
+	_ = server.Register(&foo)
+	err := HandleFunc(server, "Strings.Upper", func(s string) (string, error) { return strings.ToUpper(s), nil })
+	_assert(err == nil, "HandleFunc() error:%v", err)
+	err = HandleFunc(server, "Strings.Split", func(args Args) ([]int, error) {
+		if args.Num2 == 0 {
+			return nil, errors.New("empty")
+		}
+		return []int{args.Num1, args.Num2}, nil
+	})
+	_assert(err == nil, "HandleFunc() error:%v", err)
+	l, _ := net.Listen("tcp", "127.0.0.1:0")
+	defer l.Close()
+	go server.Accept(l)
+	client, err := Dial("tcp", l.Addr().String())
+	_assert(err == nil, "Dial() error:%v", err)
+	defer client.Close()
+	ctx := context.Background()
+
+	upper, err := CallTyped[string, string](ctx, client, "Strings.Upper", "abc")
+	_assert(err == nil && upper == "ABC", "call Strings.Upper error:%v reply:%s", err, upper)
+	split, err := CallTyped[Args, []int](ctx, client, "Strings.Split", Args{Num1: 1, Num2: 2})
+	_assert(err == nil && len(split) == 2 && split[1] == 2, "call Strings.Split error:%v reply:%v", err, split)
+	_, err = CallTyped[Args, []int](ctx, client, "Strings.Split", Args{Num1: 1})
+	_assert(err != nil && err.Error() == "empty", "expect the error of the function, but got %v", err)
+	sum, err := CallTyped[Args, int](ctx, client, "Foo.Sum", Args{Num1: 1, Num2: 2})
+	_assert(err == nil && sum == 3, "call Foo.Sum error:%v reply:%d", err, sum)
+
+	t.Run("invalid", func(t *testing.T) {
+		upper := func(s string) (string, error) { return s, nil }
+		_assert(HandleFunc(server, "Strings.Upper", upper) != nil, "expect an error for a duplicate method")
+		_assert(HandleFunc(server, "Foo.Upper", upper) != nil, "expect an error for a service registered by Register")
+		_assert(HandleFunc(server, "Upper", upper) != nil, "expect an error for an ill-formed name")
+		_assert(HandleFunc[string, string](server, "Strings.Nil", nil) != nil, "expect an error for a nil function")
+	})
+}
@@ -0,0 +1,107 @@
+package rpc
+
+import (
+	"github.com/GallifreyGoTutoural/ggt-rpc/codec"
+	"log"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// goAwayMethod is the reserved service method of the GOAWAY control frame, sent by the server before it closes
+// a connection. The client answers with a GOAWAY once it stops sending calls.
+const goAwayMethod = "_ggtrpc_.GoAway"
+
+// isGoAway reports whether h is a GOAWAY control frame.
+func isGoAway(h *codec.Header) bool {
+	return h.ServiceMethod == goAwayMethod && h.Error == ""
+}
+
+const (
+	drainPollInterval  = 10 * time.Millisecond // interval of checking whether a connection is drained after a GOAWAY
+	drainWindow        = time.Second           // how long requests are still read after a GOAWAY the client doesn't answer
+	defaultGoAwayGrace = 10 * time.Second
+)
+
+// connActivity tracks the requests of a connection.
+type connActivity struct {
+	inflight   int64 // number of requests being handled
+	lastActive int64 // UnixNano time of the last request read or done
+	goneAway   int32 // 1 once the client has answered the GOAWAY
+}
+
+func (a *connActivity) begin() {
+	atomic.AddInt64(&a.inflight, 1)
+	atomic.StoreInt64(&a.lastActive, time.Now().UnixNano())
+}
+
+func (a *connActivity) end() {
+	atomic.StoreInt64(&a.lastActive, time.Now().UnixNano())
+	atomic.AddInt64(&a.inflight, -1)
+}
+
+func (a *connActivity) idle() time.Duration {
+	if atomic.LoadInt64(&a.inflight) > 0 {
+		return 0
+	}
+	return time.Since(time.Unix(0, atomic.LoadInt64(&a.lastActive)))
+}
+
+// watchLifetime sends a GOAWAY and closes cc once the connection has been idle for IdleTimeout,
+// or has lived for MaxConnectionAge. It returns when done is closed or cc is closed.
+func (server *Server) watchLifetime(cc codec.Codec, sending *sync.Mutex, activity *connActivity, done <-chan struct{}) {
+	var ageC, idleC <-chan time.Time
+	if server.MaxConnectionAge > 0 {
+		ageTimer := time.NewTimer(server.MaxConnectionAge)
+		defer ageTimer.Stop()
+		ageC = ageTimer.C
+	}
+	if server.IdleTimeout > 0 {
+		idleTicker := time.NewTicker(server.IdleTimeout / 2)
+		defer idleTicker.Stop()
+		idleC = idleTicker.C
+	}
+	for {
+		select {
+		case <-done:
+			return
+		case <-ageC:
+			server.goAway(cc, sending, activity, server.MaxConnectionAgeGrace, "max connection age", done)
+			return
+		case <-idleC:
+			if activity.idle() > server.IdleTimeout {
+				server.goAway(cc, sending, activity, server.MaxConnectionAgeGrace, "idle timeout", done)
+				return
+			}
+		}
+	}
+}
+
+// goAway sends a GOAWAY, and closes cc once the client has answered it, or drainWindow has passed,
+// and the requests are done. It waits at most grace, 0 means defaultGoAwayGrace.
+func (server *Server) goAway(cc codec.Codec, sending *sync.Mutex, activity *connActivity, grace time.Duration, reason string, done <-chan struct{}) {
+	log.Printf("rpc server: %s, go away", reason)
+	server.sendResponse(cc, &codec.Header{ServiceMethod: goAwayMethod}, controlBody, sending)
+	if grace <= 0 {
+		grace = defaultGoAwayGrace
+	}
+	graceTimer := time.NewTimer(grace)
+	defer graceTimer.Stop()
+	drainC := time.After(drainWindow)
+	t := time.NewTicker(drainPollInterval)
+	defer t.Stop()
+	for atomic.LoadInt64(&activity.inflight) > 0 || atomic.LoadInt32(&activity.goneAway) == 0 && drainC != nil {
+		select {
+		case <-done:
+			return
+		case <-drainC:
+			drainC = nil
+		case <-graceTimer.C:
+			log.Printf("rpc server: %d requests are still in-flight after %s, close the connection", atomic.LoadInt64(&activity.inflight), grace)
+			_ = cc.Close()
+			return
+		case <-t.C:
+		}
+	}
+	_ = cc.Close()
+}
@@ -0,0 +1,72 @@
+package rpc
+
+import (
+	"context"
+	"encoding/json"
+	"github.com/GallifreyGoTutoural/ggt-rpc/codec"
+	"net"
+	"testing"
+	"time"
+)
+
+func startLifetimeServer(server *Server) string {
+	var foo Foo
+	_ = server.Register(&foo)
+	l, _ := net.Listen("tcp", ":0")
+	go server.Accept(l)
+	return l.Addr().String()
+}
+
+func TestServer_IdleTimeout(t *testing.T) {
+	addr := startLifetimeServer(&Server{IdleTimeout: 100 * time.Millisecond})
+	client, err := Dial("tcp", addr)
+	_assert(err == nil, "Dial() error:%v", err)
+	defer client.Close()
+	var reply int
+	err = client.Call(context.Background(), "Foo.Sum", &Args{Num1: 1, Num2: 2}, &reply)
+	_assert(err == nil && reply == 3, "call Foo.Sum error:%v reply:%d", err, reply)
+
+	time.Sleep(300 * time.Millisecond)
+	_assert(!client.IsAvailable(), "expect idle client to be unavailable")
+	err = client.Call(context.Background(), "Foo.Sum", &Args{Num1: 1, Num2: 2}, &reply)
+	_assert(err == ErrShutdown, "expect ErrShutdown, but got %v", err)
+}
+
+func TestServer_MaxConnectionAge(t *testing.T) {
+	addr := startLifetimeServer(&Server{MaxConnectionAge: 100 * time.Millisecond, MaxConnectionAgeGrace: 2 * time.Second})
+	client, err := Dial("tcp", addr)
+	_assert(err == nil, "Dial() error:%v", err)
+	defer client.Close()
+	call := client.Go("Foo.Sleep", &Args{Num1: 1, Num2: 2}, new(int), nil)
+
+	time.Sleep(200 * time.Millisecond)
+	_assert(client.IsDraining(), "expect client to be draining")
+	_assert(!client.IsAvailable(), "expect draining client to be unavailable")
+	<-call.Done
+	_assert(call.Error == nil && *call.Reply.(*int) == 2, "call Foo.Sleep error:%v", call.Error)
+
+	time.Sleep(100 * time.Millisecond)
+	_assert(!client.IsDraining(), "expect client to be shut down after draining")
+}
+
+func TestServer_GoAwayDrain(t *testing.T) {
+	addr := startLifetimeServer(&Server{IdleTimeout: 100 * time.Millisecond})
+	conn, err := net.Dial("tcp", addr)
+	_assert(err == nil, "Dial() error:%v", err)
+	defer conn.Close()
+	_ = json.NewEncoder(conn).Encode(DefaultOption)
+	cc := codec.NewGobCodec(conn)
+
+	// a client that doesn't answer the GOAWAY, like one whose request was already on the wire
+	var h codec.Header
+	err = cc.ReadHeader(&h)
+	_assert(err == nil && isGoAway(&h), "expect a GOAWAY, but got %+v error:%v", h, err)
+	_ = cc.ReadBody(nil)
+	err = cc.Write(&codec.Header{ServiceMethod: "Foo.Sum", Seq: 1}, &Args{Num1: 1, Num2: 2})
+	_assert(err == nil, "write request error:%v", err)
+	var reply int
+	err = cc.ReadHeader(&h)
+	_assert(err == nil && h.Seq == 1 && h.Error == "", "read response header %+v error:%v", h, err)
+	err = cc.ReadBody(&reply)
+	_assert(err == nil && reply == 3, "read response body error:%v reply:%d", err, reply)
+}
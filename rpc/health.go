@@ -0,0 +1,109 @@
+package rpc
+
+import (
+	"net/http"
+	"strings"
+	"sync"
+)
+
+// Serving statuses answered by the Health service.
+const (
+	HealthServing        = "SERVING"
+	HealthNotServing     = "NOT_SERVING"
+	HealthServiceUnknown = "SERVICE_UNKNOWN"
+)
+
+const defaultHealthPath = "/_ggtrpc_/health"
+
+// Health is the built-in health service, which answers the serving status of a service or of the whole server.
+type Health struct {
+	server   *Server
+	mu       sync.RWMutex      // protect following
+	statuses map[string]string // statuses set by the application, keyed by service name
+}
+
+// RegisterHealth registers the Health service on the server, and returns it for setting statuses.
+func (server *Server) RegisterHealth() (*Health, error) {
+	h := &Health{server: server, statuses: make(map[string]string)}
+	if err := server.Register(h); err != nil {
+		return nil, err
+	}
+	server.health.Store(h)
+	return h, nil
+}
+
+// RegisterHealth registers the Health service on the DefaultServer.
+func RegisterHealth() (*Health, error) { return DefaultServer.RegisterHealth() }
+
+// SetServingStatus sets the serving status of the service, "" means the whole server.
+func (h *Health) SetServingStatus(service string, serving bool) {
+	status := HealthNotServing
+	if serving {
+		status = HealthServing
+	}
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.statuses[service] = status
+}
+
+// Shutdown sets the whole server not serving, e.g. before a graceful shutdown.
+func (h *Health) Shutdown() { h.SetServingStatus("", false) }
+
+// Resume sets the whole server serving again.
+func (h *Health) Resume() { h.SetServingStatus("", true) }
+
+// Serving reports whether the whole server is serving.
+func (h *Health) Serving() bool {
+	return h.status("") == HealthServing
+}
+
+// Check replies the serving status of the service, "" means the whole server.
+func (h *Health) Check(service string, status *string) error {
+	*status = h.status(service)
+	return nil
+}
+
+func (h *Health) status(service string) string {
+	h.mu.RLock()
+	defer h.mu.RUnlock()
+	if h.statuses[""] == HealthNotServing {
+		return HealthNotServing
+	}
+	if status, ok := h.statuses[service]; ok {
+		return status
+	}
+	if service == "" {
+		return HealthServing
+	}
+	if _, ok := h.server.serviceMap.Load(service); ok {
+		return HealthServing
+	}
+	return HealthServiceUnknown
+}
+
+// ServeHTTP answers the status of the service in the "service" query, with 200 if serving, 404 if unknown, else 503.
+func (h *Health) ServeHTTP(w http.ResponseWriter, req *http.Request) {
+	status := h.status(strings.TrimSpace(req.URL.Query().Get("service")))
+	switch status {
+	case HealthServing:
+		w.WriteHeader(http.StatusOK)
+	case HealthServiceUnknown:
+		w.WriteHeader(http.StatusNotFound)
+	default:
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}
+	_, _ = w.Write([]byte(status + "\n"))
+}
+
+// healthHTTP serves the Health service of the server over HTTP, if it's registered.
+type healthHTTP struct {
+	*Server
+}
+
+func (server healthHTTP) ServeHTTP(w http.ResponseWriter, req *http.Request) {
+	if h := server.health.Load(); h != nil {
+		h.ServeHTTP(w, req)
+		return
+	}
+	http.NotFound(w, req)
+}
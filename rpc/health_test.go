@@ -0,0 +1,59 @@
+package rpc
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestHealth(t *testing.T) {
+	var foo Foo
+	server := NewServer()
+	_ = server.Register(&foo)
+	h, err := server.RegisterHealth()
+	_assert(err == nil, "RegisterHealth() error:%v", err)
+	check := func(service string) string {
+		var status string
+		_ = h.Check(service, &status)
+		return status
+	}
+	_assert(check("") == HealthServing, "expect the server serving, but got %s", check(""))
+	_assert(check("Foo") == HealthServing, "expect Foo serving, but got %s", check("Foo"))
+	_assert(check("Bar") == HealthServiceUnknown, "expect Bar unknown, but got %s", check("Bar"))
+
+	t.Run("per service", func(t *testing.T) {
+		h.SetServingStatus("Foo", false)
+		defer h.SetServingStatus("Foo", true)
+		_assert(check("Foo") == HealthNotServing, "expect Foo not serving, but got %s", check("Foo"))
+		_assert(check("") == HealthServing, "expect the server serving, but got %s", check(""))
+	})
+	t.Run("shutdown", func(t *testing.T) {
+		h.Shutdown()
+		_assert(check("") == HealthNotServing && check("Foo") == HealthNotServing, "expect nothing serving after shutdown")
+		_assert(!h.Serving(), "expect the server not serving after shutdown")
+		h.Resume()
+		_assert(check("Foo") == HealthServing, "expect Foo serving after resume, but got %s", check("Foo"))
+	})
+	t.Run("http", func(t *testing.T) {
+		ts := httptest.NewServer(healthHTTP{server})
+		defer ts.Close()
+		for query, code := range map[string]int{"": 200, "?service=Foo": 200, "?service=Bar": 404} {
+			resp, err := http.Get(ts.URL + query)
+			_assert(err == nil && resp.StatusCode == code, "GET %q error:%v, expect status %d", query, err, code)
+			_ = resp.Body.Close()
+		}
+		h.Shutdown()
+		defer h.Resume()
+		resp, err := http.Get(ts.URL)
+		_assert(err == nil && resp.StatusCode == http.StatusServiceUnavailable, "expect 503 after shutdown, error:%v", err)
+		_ = resp.Body.Close()
+	})
+	t.Run("http after unregister", func(t *testing.T) {
+		ts := httptest.NewServer(healthHTTP{server})
+		defer ts.Close()
+		_ = server.Unregister("Health")
+		resp, err := http.Get(ts.URL)
+		_assert(err == nil && resp.StatusCode == http.StatusNotFound, "expect 404 after unregister, error:%v", err)
+		_ = resp.Body.Close()
+	})
+}
@@ -0,0 +1,63 @@
+package rpc
+
+import (
+	"github.com/GallifreyGoTutoural/ggt-rpc/codec"
+	"log"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// Heartbeat control frames carry one of these reserved service methods and an empty body.
+// A ping is answered with a pong carrying the same sequence number.
+const (
+	pingMethod = "_ggtrpc_.Ping"
+	pongMethod = "_ggtrpc_.Pong"
+)
+
+// controlBody is the body of control frames.
+var controlBody = struct{}{}
+
+// isPing reports whether h is a ping control frame.
+// A net/rpc server echoes the service method of a failed ping, so error responses are never pings.
+func isPing(h *codec.Header) bool {
+	return h.ServiceMethod == pingMethod && h.Error == ""
+}
+
+// isPong reports whether h is a pong control frame.
+func isPong(h *codec.Header) bool {
+	return h.ServiceMethod == pongMethod
+}
+
+// heartbeatTimeout returns the timeout of a heartbeat with interval, which defaults to three intervals.
+func heartbeatTimeout(interval, timeout time.Duration) time.Duration {
+	if interval > 0 && timeout == 0 {
+		return 3 * interval
+	}
+	return timeout
+}
+
+// heartbeat sends a ping every interval and closes cc if nothing has been received for timeout.
+// lastRecv holds the UnixNano time of the last received frame. It returns when done is closed or cc is closed.
+func heartbeat(cc codec.Codec, sending *sync.Mutex, lastRecv *int64, interval, timeout time.Duration, done <-chan struct{}) {
+	t := time.NewTicker(interval)
+	defer t.Stop()
+	for {
+		select {
+		case <-done:
+			return
+		case <-t.C:
+		}
+		if time.Since(time.Unix(0, atomic.LoadInt64(lastRecv))) > timeout {
+			log.Printf("rpc: heartbeat timeout: nothing received within %s, close the connection", timeout)
+			_ = cc.Close()
+			return
+		}
+		sending.Lock()
+		err := cc.Write(&codec.Header{ServiceMethod: pingMethod}, controlBody)
+		sending.Unlock()
+		if err != nil {
+			return
+		}
+	}
+}
@@ -0,0 +1,65 @@
+package rpc
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net"
+	"testing"
+	"time"
+)
+
+func TestHeartbeat(t *testing.T) {
+	var foo Foo
+	server := NewServer()
+	_ = server.Register(&foo)
+	l, _ := net.Listen("tcp", ":0")
+	defer l.Close()
+	go server.Accept(l)
+	opt := &Option{HeartbeatInterval: 50 * time.Millisecond, HeartbeatTimeout: 200 * time.Millisecond}
+
+	t.Run("alive", func(t *testing.T) {
+		client, err := Dial("tcp", l.Addr().String(), opt)
+		_assert(err == nil, "Dial() error:%v", err)
+		defer client.Close()
+		time.Sleep(400 * time.Millisecond)
+		_assert(client.IsAvailable(), "expect client to be available")
+		var reply int
+		err = client.Call(context.Background(), "Foo.Sum", &Args{Num1: 1, Num2: 2}, &reply)
+		_assert(err == nil && reply == 3, "call Foo.Sum error:%v reply:%d", err, reply)
+	})
+	t.Run("dead server", func(t *testing.T) {
+		dead, _ := net.Listen("tcp", ":0")
+		defer dead.Close()
+		go func() {
+			// accept connections, but never answer
+			for {
+				if _, err := dead.Accept(); err != nil {
+					return
+				}
+			}
+		}()
+		client, err := Dial("tcp", dead.Addr().String(), opt)
+		_assert(err == nil, "Dial() error:%v", err)
+		call := client.Go("Foo.Sum", &Args{Num1: 1, Num2: 2}, new(int), nil)
+		time.Sleep(300 * time.Millisecond)
+		_assert(!client.IsAvailable(), "expect client to be unavailable")
+		select {
+		case <-call.Done:
+			_assert(call.Error != nil, "expect pending call to fail")
+		case <-time.After(time.Second):
+			t.Fatal("expect pending call to be terminated")
+		}
+	})
+	t.Run("dead client", func(t *testing.T) {
+		conn, err := net.Dial("tcp", l.Addr().String())
+		_assert(err == nil, "net.Dial() error:%v", err)
+		defer conn.Close()
+		// send options with heartbeat, but never answer pings
+		_ = json.NewEncoder(conn).Encode(&Option{MagicNumber: MagicNumber, CodecType: DefaultOption.CodecType,
+			HeartbeatInterval: opt.HeartbeatInterval, HeartbeatTimeout: opt.HeartbeatTimeout})
+		_ = conn.SetReadDeadline(time.Now().Add(time.Second))
+		_, err = io.Copy(io.Discard, conn)
+		_assert(err == nil, "expect server to close the connection, but got %v", err)
+	})
+}
@@ -0,0 +1,188 @@
+package rpc
+
+import (
+	"bytes"
+	"encoding/json"
+	"io"
+	"net/http"
+	"sync"
+)
+
+const jsonRPCVersion = "2.0"
+
+// JSON-RPC 2.0 error codes
+const (
+	JSONRPCParseError     = -32700 // invalid JSON was received by the server
+	JSONRPCInvalidRequest = -32600 // the JSON sent is not a valid request object
+	JSONRPCMethodNotFound = -32601 // the method does not exist
+	JSONRPCInvalidParams  = -32602 // invalid method parameters
+	JSONRPCInternalError  = -32603 // internal JSON-RPC error
+	JSONRPCServerError    = -32000 // the method returned an error
+)
+
+type jsonRPCRequest struct {
+	Version string          `json:"jsonrpc"`
+	Method  string          `json:"method"`
+	Params  json.RawMessage `json:"params,omitempty"`
+	ID      json.RawMessage `json:"id,omitempty"` // nil means a notification
+}
+
+// JSONRPCError is the error object of a JSON-RPC 2.0 response.
+type JSONRPCError struct {
+	Code    int    `json:"code"`
+	Message string `json:"message"`
+}
+
+func (e *JSONRPCError) Error() string {
+	return e.Message
+}
+
+type jsonRPCResponse struct {
+	Version string          `json:"jsonrpc"`
+	Result  interface{}     `json:"result,omitempty"`
+	Error   *JSONRPCError   `json:"error,omitempty"`
+	ID      json.RawMessage `json:"id"`
+}
+
+var jsonNull = json.RawMessage("null")
+
+type jsonRPCHTTP struct {
+	*Server
+}
+
+// JSONRPCHandler returns an HTTP handler that serves JSON-RPC 2.0 requests (single and batch) sent via POST.
+// The method of a request is the "Service.Method" of a registered method, and its params are decoded into the ArgType.
+func (server *Server) JSONRPCHandler() http.Handler {
+	return jsonRPCHTTP{server}
+}
+
+func (server jsonRPCHTTP) ServeHTTP(w http.ResponseWriter, req *http.Request) {
+	if req.Method != "POST" {
+		w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+		w.WriteHeader(http.StatusMethodNotAllowed)
+		_, _ = io.WriteString(w, "405 must POST\n")
+		return
+	}
+	body, err := io.ReadAll(server.limitBody(w, req))
+	if err != nil {
+		w.WriteHeader(bodyErrorStatus(err))
+		return
+	}
+	body = bytes.TrimSpace(body)
+
+	var result interface{}
+	if len(body) > 0 && body[0] == '[' {
+		result = server.serveBatch(body)
+	} else if resp := server.serveRequest(body); resp != nil {
+		result = resp
+	}
+	if result == nil {
+		// notifications only, nothing to reply
+		w.WriteHeader(http.StatusNoContent)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(result)
+}
+
+// serveBatch handles a batch of requests concurrently, and returns the responses in the order of requests.
+func (server jsonRPCHTTP) serveBatch(body []byte) interface{} {
+	var raws []json.RawMessage
+	if err := json.Unmarshal(body, &raws); err != nil {
+		return newJSONRPCError(jsonNull, JSONRPCParseError, "parse error: "+err.Error())
+	}
+	if len(raws) == 0 {
+		return newJSONRPCError(jsonNull, JSONRPCInvalidRequest, "invalid request: empty batch")
+	}
+	responses := make([]*jsonRPCResponse, len(raws))
+	var wg sync.WaitGroup
+	for i, raw := range raws {
+		wg.Add(1)
+		go func(i int, raw json.RawMessage) {
+			defer wg.Done()
+			responses[i] = server.serveRequest(raw)
+		}(i, raw)
+	}
+	wg.Wait()
+	results := make([]*jsonRPCResponse, 0, len(responses))
+	for _, resp := range responses {
+		if resp != nil {
+			results = append(results, resp)
+		}
+	}
+	if len(results) == 0 {
+		return nil
+	}
+	return results
+}
+
+// serveRequest handles a single request, and returns nil if the request is a notification.
+func (server jsonRPCHTTP) serveRequest(raw []byte) *jsonRPCResponse {
+	var r jsonRPCRequest
+	if err := json.Unmarshal(raw, &r); err != nil {
+		if _, ok := err.(*json.SyntaxError); ok {
+			return newJSONRPCError(jsonNull, JSONRPCParseError, "parse error: "+err.Error())
+		}
+		return newJSONRPCError(jsonNull, JSONRPCInvalidRequest, "invalid request: "+err.Error())
+	}
+	id := r.ID
+	if id == nil {
+		id = jsonNull
+	}
+	if r.Version != jsonRPCVersion || r.Method == "" {
+		return newJSONRPCError(id, JSONRPCInvalidRequest, "invalid request")
+	}
+	result, rpcErr := server.invoke(r.Method, r.Params)
+	if r.ID == nil {
+		return nil
+	}
+	if rpcErr != nil {
+		return &jsonRPCResponse{Version: jsonRPCVersion, Error: rpcErr, ID: id}
+	}
+	return &jsonRPCResponse{Version: jsonRPCVersion, Result: result, ID: id}
+}
+
+// invoke decodes params into the ArgType of serviceMethod and calls it.
+func (server jsonRPCHTTP) invoke(serviceMethod string, params json.RawMessage) (interface{}, *JSONRPCError) {
+	svc, mtype, err := server.findService(serviceMethod, "")
+	if err != nil {
+		return nil, &JSONRPCError{Code: JSONRPCMethodNotFound, Message: err.Error()}
+	}
+	argv := mtype.newArgv()
+	replyv := mtype.newReplyv()
+	if err = decodeJSONParams(params, argvPointer(argv)); err != nil {
+		return nil, &JSONRPCError{Code: JSONRPCInvalidParams, Message: "invalid params: " + err.Error()}
+	}
+	if err = svc.call(mtype, argv, replyv); err != nil {
+		return nil, &JSONRPCError{Code: JSONRPCServerError, Message: err.Error()}
+	}
+	return replyv.Interface(), nil
+}
+
+// decodeJSONParams decodes params into argv.
+// Params may be given by-name as an object, or by-position as an array holding the single argument.
+func decodeJSONParams(params json.RawMessage, argv interface{}) error {
+	params = bytes.TrimSpace(params)
+	if len(params) == 0 || bytes.Equal(params, jsonNull) {
+		return nil
+	}
+	if params[0] == '[' {
+		var positional []json.RawMessage
+		if err := json.Unmarshal(params, &positional); err != nil {
+			return err
+		}
+		if len(positional) != 1 {
+			return &JSONRPCError{Code: JSONRPCInvalidParams, Message: "expect exactly one positional param"}
+		}
+		params = positional[0]
+	}
+	return json.Unmarshal(params, argv)
+}
+
+func newJSONRPCError(id json.RawMessage, code int, message string) *jsonRPCResponse {
+	return &jsonRPCResponse{
+		Version: jsonRPCVersion,
+		Error:   &JSONRPCError{Code: code, Message: message},
+		ID:      id,
+	}
+}
@@ -0,0 +1,74 @@
+package rpc
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func postJSONRPC(t *testing.T, url, body string) (int, []byte) {
+	resp, err := http.Post(url, "application/json", strings.NewReader(body))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+	var raw json.RawMessage
+	if resp.StatusCode == http.StatusOK {
+		_ = json.NewDecoder(resp.Body).Decode(&raw)
+	}
+	return resp.StatusCode, raw
+}
+
+func TestServer_JSONRPCHandler(t *testing.T) {
+	var foo Foo
+	server := NewServer()
+	_ = server.Register(&foo)
+	ts := httptest.NewServer(server.JSONRPCHandler())
+	defer ts.Close()
+
+	t.Run("single", func(t *testing.T) {
+		_, raw := postJSONRPC(t, ts.URL, `{"jsonrpc":"2.0","method":"Foo.Sum","params":{"Num1":1,"Num2":2},"id":1}`)
+		var resp struct {
+			Result int
+			ID     int
+		}
+		_ = json.Unmarshal(raw, &resp)
+		_assert(resp.Result == 3 && resp.ID == 1, "unexpected response: %s", raw)
+	})
+	t.Run("positional params", func(t *testing.T) {
+		_, raw := postJSONRPC(t, ts.URL, `{"jsonrpc":"2.0","method":"Foo.Sum","params":[{"Num1":2,"Num2":3}],"id":"a"}`)
+		var resp struct{ Result int }
+		_ = json.Unmarshal(raw, &resp)
+		_assert(resp.Result == 5, "unexpected response: %s", raw)
+	})
+	t.Run("batch", func(t *testing.T) {
+		_, raw := postJSONRPC(t, ts.URL, `[
+			{"jsonrpc":"2.0","method":"Foo.Sum","params":{"Num1":1,"Num2":1},"id":1},
+			{"jsonrpc":"2.0","method":"Foo.Sum","params":{"Num1":1,"Num2":1}},
+			{"jsonrpc":"2.0","method":"Foo.Missing","id":2},
+			{"jsonrpc":"2.0","method":"Foo.Sum","params":"bad","id":3}
+		]`)
+		var resp []struct {
+			Result int
+			Error  *JSONRPCError
+			ID     int
+		}
+		_ = json.Unmarshal(raw, &resp)
+		_assert(len(resp) == 3, "expect 3 responses, but got %s", raw)
+		_assert(resp[0].ID == 1 && resp[0].Result == 2, "unexpected response: %+v", resp[0])
+		_assert(resp[1].ID == 2 && resp[1].Error != nil && resp[1].Error.Code == JSONRPCMethodNotFound, "unexpected response: %+v", resp[1])
+		_assert(resp[2].ID == 3 && resp[2].Error != nil && resp[2].Error.Code == JSONRPCInvalidParams, "unexpected response: %+v", resp[2])
+	})
+	t.Run("notification", func(t *testing.T) {
+		code, _ := postJSONRPC(t, ts.URL, `{"jsonrpc":"2.0","method":"Foo.Sum","params":{"Num1":1,"Num2":2}}`)
+		_assert(code == http.StatusNoContent, "expect status 204, but got %d", code)
+	})
+	t.Run("parse error", func(t *testing.T) {
+		_, raw := postJSONRPC(t, ts.URL, `{"jsonrpc":`)
+		var resp struct{ Error *JSONRPCError }
+		_ = json.Unmarshal(raw, &resp)
+		_assert(resp.Error != nil && resp.Error.Code == JSONRPCParseError, "unexpected response: %s", raw)
+	})
+}
@@ -0,0 +1,32 @@
+package rpc
+
+import (
+	"context"
+	"github.com/GallifreyGoTutoural/ggt-rpc/version"
+)
+
+type metadataCtxKey struct{}
+
+// WithMetadata returns a copy of ctx carrying key=value in the request metadata,
+// which Client.Call sends in the request header.
+func WithMetadata(ctx context.Context, key, value string) context.Context {
+	old := MetadataFromContext(ctx)
+	md := make(map[string]string, len(old)+1)
+	for k, v := range old {
+		md[k] = v
+	}
+	md[key] = value
+	return context.WithValue(ctx, metadataCtxKey{}, md)
+}
+
+// MetadataFromContext returns the request metadata carried by ctx, nil if there is none.
+// The map must not be modified.
+func MetadataFromContext(ctx context.Context) map[string]string {
+	md, _ := ctx.Value(metadataCtxKey{}).(map[string]string)
+	return md
+}
+
+// WithVersion returns a copy of ctx requesting a version of the service matching constraint, see version.Matches.
+func WithVersion(ctx context.Context, constraint string) context.Context {
+	return WithMetadata(ctx, version.MetadataKey, constraint)
+}
@@ -0,0 +1,179 @@
+package rpc
+
+import (
+	"bufio"
+	"crypto/tls"
+	"errors"
+	"log"
+	"net"
+	"net/http"
+	"sync"
+	"time"
+)
+
+const (
+	tlsHandshake        = 0x16 // record type of a handshake, the first byte of a TLS connection
+	tlsMajorVersion     = 0x03 // major version of every SSL 3.0 and TLS record
+	defaultSniffTimeout = 10 * time.Second
+)
+
+var httpMethods = []string{"GET ", "POST ", "PUT ", "DELETE ", "HEAD ", "OPTIONS ", "PATCH ", "CONNECT ", "TRACE ", "PRI "}
+
+// Mux serves raw RPC, HTTP and TLS connections on a single listener, by peeking the first bytes of each connection.
+// TLS connections are terminated and sniffed again.
+type Mux struct {
+	server    *Server
+	handler   http.Handler
+	tlsConfig *tls.Config
+	// SniffTimeout limits the time to read the first bytes of a connection and to complete the TLS handshake,
+	// 0 means 10s.
+	SniffTimeout time.Duration
+}
+
+// NewMux returns a new Mux.
+// If handler is nil, http.DefaultServeMux is used, where HandleHTTP registers the RPC and debug paths.
+// If tlsConfig is nil, TLS connections are not accepted.
+func NewMux(server *Server, handler http.Handler, tlsConfig *tls.Config) *Mux {
+	if handler == nil {
+		handler = http.DefaultServeMux
+	}
+	return &Mux{
+		server:    server,
+		handler:   handler,
+		tlsConfig: tlsConfig,
+	}
+}
+
+// Serve accepts connections on the listener and serves each one with the protocol it speaks.
+func (m *Mux) Serve(lis net.Listener) error {
+	httpLis := newConnListener(lis.Addr())
+	defer func() {
+		_ = httpLis.Close()
+	}()
+	go func() {
+		_ = http.Serve(httpLis, m.handler)
+	}()
+	for {
+		conn, err := lis.Accept()
+		if err != nil {
+			log.Printf("rpc mux: accept error: %v", err)
+			return err
+		}
+		go m.serveConn(conn, httpLis, m.tlsConfig != nil)
+	}
+}
+
+func (m *Mux) serveConn(conn net.Conn, httpLis *connListener, allowTLS bool) {
+	timeout := m.SniffTimeout
+	if timeout <= 0 {
+		timeout = defaultSniffTimeout
+	}
+	_ = conn.SetReadDeadline(time.Now().Add(timeout))
+	br := bufio.NewReader(conn)
+	if _, err := br.Peek(1); err != nil {
+		_ = conn.Close()
+		return
+	}
+	conn = &peekedConn{Conn: conn, r: br}
+	switch {
+	case allowTLS && isTLS(br):
+		tlsConn := tls.Server(conn, m.tlsConfig)
+		_ = tlsConn.SetDeadline(time.Now().Add(timeout))
+		if err := tlsConn.Handshake(); err != nil {
+			log.Printf("rpc mux: tls handshake error: %v", err)
+			_ = conn.Close()
+			return
+		}
+		_ = tlsConn.SetDeadline(time.Time{})
+		m.serveConn(tlsConn, httpLis, false)
+	case isHTTP(br):
+		_ = conn.SetReadDeadline(time.Time{})
+		if err := httpLis.put(conn); err != nil {
+			_ = conn.Close()
+		}
+	default:
+		_ = conn.SetReadDeadline(time.Time{})
+		m.server.ServerConn(conn)
+	}
+}
+
+// isTLS reports whether the buffered connection starts with a TLS handshake record, the bytes 0x16 0x03 0x00-0x04,
+// which neither RPC options nor a net/rpc gob stream start with.
+func isTLS(br *bufio.Reader) bool {
+	header, err := br.Peek(3)
+	return err == nil && header[0] == tlsHandshake && header[1] == tlsMajorVersion && header[2] <= 0x04
+}
+
+// isHTTP reports whether the buffered connection starts with an HTTP request line.
+func isHTTP(br *bufio.Reader) bool {
+	first, _ := br.Peek(1)
+	if first[0] < 'A' || first[0] > 'Z' {
+		return false
+	}
+	for _, method := range httpMethods {
+		if method[0] != first[0] {
+			continue
+		}
+		if prefix, err := br.Peek(len(method)); err == nil && string(prefix) == method {
+			return true
+		}
+	}
+	return false
+}
+
+// peekedConn is a connection whose reads are served by r, which holds the peeked bytes.
+type peekedConn struct {
+	net.Conn
+	r *bufio.Reader
+}
+
+func (c *peekedConn) Read(p []byte) (int, error) {
+	return c.r.Read(p)
+}
+
+var errMuxClosed = errors.New("rpc mux: listener closed")
+
+// connListener is a net.Listener whose connections are handed over by the Mux.
+type connListener struct {
+	addr  net.Addr
+	conns chan net.Conn
+	done  chan struct{}
+	once  sync.Once
+}
+
+func newConnListener(addr net.Addr) *connListener {
+	return &connListener{
+		addr:  addr,
+		conns: make(chan net.Conn),
+		done:  make(chan struct{}),
+	}
+}
+
+func (l *connListener) put(conn net.Conn) error {
+	select {
+	case l.conns <- conn:
+		return nil
+	case <-l.done:
+		return errMuxClosed
+	}
+}
+
+func (l *connListener) Accept() (net.Conn, error) {
+	select {
+	case conn := <-l.conns:
+		return conn, nil
+	case <-l.done:
+		return nil, errMuxClosed
+	}
+}
+
+func (l *connListener) Close() error {
+	l.once.Do(func() {
+		close(l.done)
+	})
+	return nil
+}
+
+func (l *connListener) Addr() net.Addr {
+	return l.addr
+}
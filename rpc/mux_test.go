@@ -0,0 +1,98 @@
+package rpc
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"io"
+	"math/big"
+	"net"
+	"net/http"
+	"testing"
+	"time"
+)
+
+func selfSignedCert(t *testing.T) tls.Certificate {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tmpl := &x509.Certificate{
+		SerialNumber: big.NewInt(1),
+		Subject:      pkix.Name{CommonName: "ggtrpc"},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+		IPAddresses:  []net.IP{net.ParseIP("127.0.0.1")},
+	}
+	der, err := x509.CreateCertificate(rand.Reader, tmpl, tmpl, &key.PublicKey, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return tls.Certificate{Certificate: [][]byte{der}, PrivateKey: key}
+}
+
+func TestMux(t *testing.T) {
+	var foo Foo
+	server := NewServer()
+	_ = server.Register(&foo)
+	handler := http.NewServeMux()
+	handler.Handle(defaultRPCPath, server)
+	handler.Handle(defaultDebugPath, debugHTTP{server})
+	tlsConfig := &tls.Config{Certificates: []tls.Certificate{selfSignedCert(t)}}
+
+	l, _ := net.Listen("tcp", "127.0.0.1:0")
+	defer l.Close()
+	mux := NewMux(server, handler, tlsConfig)
+	mux.SniffTimeout = 200 * time.Millisecond
+	go func() {
+		_ = mux.Serve(l)
+	}()
+	addr := l.Addr().String()
+	args := &Args{Num1: 1, Num2: 2}
+
+	t.Run("raw rpc", func(t *testing.T) {
+		client, err := Dial("tcp", addr)
+		_assert(err == nil, "Dial() error:%v", err)
+		defer client.Close()
+		var reply int
+		err = client.Call(context.Background(), "Foo.Sum", args, &reply)
+		_assert(err == nil && reply == 3, "call Foo.Sum error:%v reply:%d", err, reply)
+	})
+	t.Run("http connect", func(t *testing.T) {
+		client, err := DialHttp("tcp", addr)
+		_assert(err == nil, "DialHttp() error:%v", err)
+		defer client.Close()
+		var reply int
+		err = client.Call(context.Background(), "Foo.Sum", args, &reply)
+		_assert(err == nil && reply == 3, "call Foo.Sum error:%v reply:%d", err, reply)
+	})
+	t.Run("http debug", func(t *testing.T) {
+		resp, err := http.Get("http://" + addr + defaultDebugPath)
+		_assert(err == nil, "GET debug page error:%v", err)
+		defer resp.Body.Close()
+		_, _ = io.Copy(io.Discard, resp.Body)
+		_assert(resp.StatusCode == http.StatusOK, "expect status 200, but got %d", resp.StatusCode)
+	})
+	t.Run("tls", func(t *testing.T) {
+		conn, err := tls.Dial("tcp", addr, &tls.Config{InsecureSkipVerify: true})
+		_assert(err == nil, "tls.Dial() error:%v", err)
+		client, err := NewClient(conn, DefaultOption)
+		_assert(err == nil, "NewClient() error:%v", err)
+		defer client.Close()
+		var reply int
+		err = client.Call(context.Background(), "Foo.Sum", args, &reply)
+		_assert(err == nil && reply == 3, "call Foo.Sum error:%v reply:%d", err, reply)
+	})
+	t.Run("silent client", func(t *testing.T) {
+		conn, err := net.Dial("tcp", addr)
+		_assert(err == nil, "Dial() error:%v", err)
+		defer conn.Close()
+		_ = conn.SetReadDeadline(time.Now().Add(2 * time.Second))
+		_, err = conn.Read(make([]byte, 1))
+		_assert(err == io.EOF, "expect the connection to be closed, but got %v", err)
+	})
+}
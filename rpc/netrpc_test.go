@@ -0,0 +1,56 @@
+package rpc
+
+import (
+	"context"
+	"net"
+	netrpc "net/rpc"
+	"testing"
+)
+
+func TestNetRPCCompatibility(t *testing.T) {
+	var foo Foo
+	args := Args{Num1: 1, Num2: 2}
+
+	t.Run("net/rpc client", func(t *testing.T) {
+		server := NewServer()
+		_ = server.Register(&foo)
+		l, _ := net.Listen("tcp", ":0")
+		defer l.Close()
+		go server.Accept(l)
+
+		client, err := netrpc.Dial("tcp", l.Addr().String())
+		_assert(err == nil, "netrpc.Dial() error:%v", err)
+		defer client.Close()
+		var reply int
+		err = client.Call("Foo.Sum", args, &reply)
+		_assert(err == nil && reply == 3, "net/rpc call Foo.Sum error:%v reply:%d", err, reply)
+
+		err = client.Call("Foo.Missing", args, &reply)
+		_assert(err != nil, "expect an error calling a missing method")
+		err = client.Call("Foo.Sum", args, &reply)
+		_assert(err == nil && reply == 3, "net/rpc call Foo.Sum error:%v reply:%d", err, reply)
+
+		// a ggtrpc client is still served on the same listener
+		c, err := Dial("tcp", l.Addr().String())
+		_assert(err == nil, "Dial() error:%v", err)
+		defer c.Close()
+		err = c.Call(context.Background(), "Foo.Sum", args, &reply)
+		_assert(err == nil && reply == 3, "call Foo.Sum error:%v reply:%d", err, reply)
+	})
+	t.Run("net/rpc server", func(t *testing.T) {
+		server := netrpc.NewServer()
+		_ = server.Register(&foo)
+		l, _ := net.Listen("tcp", ":0")
+		defer l.Close()
+		go server.Accept(l)
+
+		client, err := DialNetRPC("tcp", l.Addr().String())
+		_assert(err == nil, "DialNetRPC() error:%v", err)
+		defer client.Close()
+		var reply int
+		err = client.Call(context.Background(), "Foo.Sum", args, &reply)
+		_assert(err == nil && reply == 3, "call Foo.Sum error:%v reply:%d", err, reply)
+		err = client.Call(context.Background(), "Foo.Missing", args, &reply)
+		_assert(err != nil, "expect an error calling a missing method")
+	})
+}
@@ -0,0 +1,327 @@
+package rpc
+
+import (
+	"context"
+	"errors"
+	"log"
+	"math/rand"
+	"sync"
+	"time"
+)
+
+// Backoff computes exponential backoff delays with jitter.
+type Backoff struct {
+	Base   time.Duration // delay of the first retry
+	Max    time.Duration // max delay, 0 means no limit
+	Factor float64       // multiplier of each retry, values below 1 mean 2
+	Jitter float64       // random fraction of the delay added or subtracted, in [0, 1]
+}
+
+// DefaultBackoff is the backoff used when none is configured.
+var DefaultBackoff = Backoff{
+	Base:   100 * time.Millisecond,
+	Max:    10 * time.Second,
+	Factor: 2,
+	Jitter: 0.2,
+}
+
+// maxBackoffDelay bounds the delays of a Backoff without Max, so that they don't overflow.
+const maxBackoffDelay = time.Duration(1 << 62)
+
+// Delay returns the delay before the retry after attempt failed attempts, which counts from 0.
+func (b Backoff) Delay(attempt int) time.Duration {
+	factor := b.Factor
+	if factor < 1 {
+		factor = 2
+	}
+	limit := float64(maxBackoffDelay)
+	if b.Max > 0 && b.Max < maxBackoffDelay {
+		limit = float64(b.Max)
+	}
+	delay := float64(b.Base)
+	for i := 0; i < attempt && delay < limit; i++ {
+		delay *= factor
+	}
+	if delay > limit {
+		delay = limit
+	}
+	if b.Jitter > 0 {
+		delay += delay * b.Jitter * (2*rand.Float64() - 1)
+	}
+	if delay > float64(maxBackoffDelay) {
+		delay = float64(maxBackoffDelay)
+	}
+	if delay < 0 {
+		delay = 0
+	}
+	return time.Duration(delay)
+}
+
+// withDefaults returns b with its zero fields set from DefaultBackoff.
+func (b Backoff) withDefaults() Backoff {
+	if b.Base == 0 {
+		b.Base = DefaultBackoff.Base
+	}
+	if b.Max == 0 {
+		b.Max = DefaultBackoff.Max
+	}
+	if b.Factor == 0 {
+		b.Factor = DefaultBackoff.Factor
+	}
+	if b.Jitter == 0 {
+		b.Jitter = DefaultBackoff.Jitter
+	}
+	return b
+}
+
+// ConnState is the state of the connection of a ReconnectingClient.
+type ConnState int
+
+const (
+	Connecting ConnState = iota
+	Connected
+	Disconnected
+	Closed
+)
+
+func (s ConnState) String() string {
+	switch s {
+	case Connecting:
+		return "connecting"
+	case Connected:
+		return "connected"
+	case Disconnected:
+		return "disconnected"
+	case Closed:
+		return "closed"
+	default:
+		return "unknown"
+	}
+}
+
+// ReconnectOption configures a ReconnectingClient.
+type ReconnectOption struct {
+	Backoff       Backoff                  // backoff between dial attempts, zero fields are taken from DefaultBackoff
+	OnStateChange func(old, new ConnState) // called in order on every state change
+}
+
+// ReconnectingClient is a client that re-dials the server whenever the connection is lost.
+// Calls that were not written to a lost connection are sent again once reconnected,
+// calls already written fail with the error of the connection.
+type ReconnectingClient struct {
+	rpcAddr string
+	opt     *Option
+	ropt    ReconnectOption
+
+	notifying   sync.Mutex // make sure state changes are notified in order
+	mu          sync.Mutex // protect following
+	client      *Client
+	state       ConnState
+	transitions [][2]ConnState // state changes to notify
+	ready       chan struct{}  // closed when connected
+	closed      chan struct{}  // closed when the user has called Close
+}
+
+// NewReconnectingClient returns a ReconnectingClient connecting to the server at the network address,
+// where network "http" dials with DialHttp. It connects in the background, and calls wait until connected.
+func NewReconnectingClient(network, address string, opt *Option, ropt *ReconnectOption) (*ReconnectingClient, error) {
+	opt, err := parseOptions(opt)
+	if err != nil {
+		return nil, err
+	}
+	r := &ReconnectingClient{
+		rpcAddr: network + "@" + address,
+		opt:     opt,
+		ropt:    ReconnectOption{Backoff: DefaultBackoff},
+		state:   Connecting,
+		ready:   make(chan struct{}),
+		closed:  make(chan struct{}),
+	}
+	if ropt != nil {
+		r.ropt = *ropt
+		r.ropt.Backoff = ropt.Backoff.withDefaults()
+	}
+	go r.reconnect()
+	return r, nil
+}
+
+// State returns the current state of the connection.
+func (r *ReconnectingClient) State() ConnState {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.state
+}
+
+// setState changes the state, r.mu must be held. The change is notified by notify once r.mu is released.
+func (r *ReconnectingClient) setState(state ConnState) {
+	old := r.state
+	if old == state {
+		return
+	}
+	r.state = state
+	if r.ropt.OnStateChange != nil {
+		r.transitions = append(r.transitions, [2]ConnState{old, state})
+	}
+}
+
+// notify calls OnStateChange with the state changes so far, r.mu must not be held.
+func (r *ReconnectingClient) notify() {
+	if r.ropt.OnStateChange == nil {
+		return
+	}
+	r.notifying.Lock()
+	defer r.notifying.Unlock()
+	r.mu.Lock()
+	transitions := r.transitions
+	r.transitions = nil
+	r.mu.Unlock()
+	for _, t := range transitions {
+		r.ropt.OnStateChange(t[0], t[1])
+	}
+}
+
+// reconnect dials the server with backoff until connected or closed.
+func (r *ReconnectingClient) reconnect() {
+	for attempt := 0; ; attempt++ {
+		client, err := XDial(r.rpcAddr, r.opt)
+		r.mu.Lock()
+		select {
+		case <-r.closed:
+			r.mu.Unlock()
+			if client != nil {
+				_ = client.Close()
+			}
+			return
+		default:
+		}
+		if err == nil {
+			r.client = client
+			r.setState(Connected)
+			close(r.ready)
+			r.mu.Unlock()
+			r.notify()
+			go r.watch(client)
+			return
+		}
+		r.setState(Disconnected)
+		r.mu.Unlock()
+		r.notify()
+
+		delay := r.ropt.Backoff.Delay(attempt)
+		log.Printf("rpc client: dial %s error: %v, retry in %s", r.rpcAddr, err, delay)
+		select {
+		case <-r.closed:
+			return
+		case <-time.After(delay):
+		}
+		r.mu.Lock()
+		select {
+		case <-r.closed:
+			// closed while waiting, the state is Closed already
+			r.mu.Unlock()
+			return
+		default:
+		}
+		r.setState(Connecting)
+		r.mu.Unlock()
+		r.notify()
+	}
+}
+
+// watch reconnects once client is shut down.
+func (r *ReconnectingClient) watch(client *Client) {
+	select {
+	case <-client.done:
+		r.lost(client)
+	case <-r.closed:
+	}
+}
+
+// lost replaces client if it's still the current one.
+func (r *ReconnectingClient) lost(client *Client) {
+	defer r.notify()
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.client != client {
+		return
+	}
+	select {
+	case <-r.closed:
+		return
+	default:
+	}
+	if client.IsDraining() {
+		// the server closes the connection once the calls already sent are answered
+		go func() {
+			<-client.done
+			_ = client.Close()
+		}()
+	} else {
+		_ = client.Close()
+	}
+	r.client = nil
+	r.ready = make(chan struct{})
+	r.setState(Disconnected)
+	r.setState(Connecting)
+	go r.reconnect()
+}
+
+// current waits until connected, and returns the client.
+func (r *ReconnectingClient) current(ctx context.Context) (*Client, error) {
+	for {
+		r.mu.Lock()
+		client, ready := r.client, r.ready
+		r.mu.Unlock()
+		if client != nil {
+			if client.IsAvailable() {
+				return client, nil
+			}
+			r.lost(client)
+			continue
+		}
+		select {
+		case <-ctx.Done():
+			return nil, errors.New("rpc client: call failed:" + ctx.Err().Error())
+		case <-r.closed:
+			return nil, ErrShutdown
+		case <-ready:
+		}
+	}
+}
+
+// Call invokes the named function, waits for it to complete, and returns its error status.
+// It waits for the connection if the client is reconnecting, until ctx is done.
+func (r *ReconnectingClient) Call(ctx context.Context, serviceMethod string, args, reply interface{}) error {
+	for {
+		client, err := r.current(ctx)
+		if err != nil {
+			return err
+		}
+		err = client.Call(ctx, serviceMethod, args, reply)
+		if !errors.Is(err, ErrShutdown) {
+			return err
+		}
+		// the call was not written to the lost connection, send it again once reconnected
+		r.lost(client)
+	}
+}
+
+var _ Caller = (*ReconnectingClient)(nil)
+
+// Close closes the client and stops reconnecting.
+func (r *ReconnectingClient) Close() error {
+	defer r.notify()
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	select {
+	case <-r.closed:
+		return ErrShutdown
+	default:
+	}
+	close(r.closed)
+	r.setState(Closed)
+	if r.client != nil {
+		return r.client.Close()
+	}
+	return nil
+}
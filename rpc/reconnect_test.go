@@ -0,0 +1,144 @@
+package rpc
+
+import (
+	"context"
+	"net"
+	"sync"
+	"testing"
+	"time"
+)
+
+// trackingListener records accepted connections, so that tests can break them.
+type trackingListener struct {
+	net.Listener
+	mu    sync.Mutex
+	conns []net.Conn
+}
+
+func (l *trackingListener) Accept() (net.Conn, error) {
+	conn, err := l.Listener.Accept()
+	if err == nil {
+		l.mu.Lock()
+		l.conns = append(l.conns, conn)
+		l.mu.Unlock()
+	}
+	return conn, err
+}
+
+func (l *trackingListener) breakConns() {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	for _, conn := range l.conns {
+		_ = conn.Close()
+	}
+	l.conns = nil
+}
+
+func TestBackoff_Delay(t *testing.T) {
+	b := Backoff{Base: 100 * time.Millisecond, Max: time.Second, Factor: 2}
+	_assert(b.Delay(0) == 100*time.Millisecond, "wrong delay of attempt 0: %s", b.Delay(0))
+	_assert(b.Delay(2) == 400*time.Millisecond, "wrong delay of attempt 2: %s", b.Delay(2))
+	_assert(b.Delay(10) == time.Second, "wrong delay of attempt 10: %s", b.Delay(10))
+	b.Jitter = 0.5
+	for i := 0; i < 10; i++ {
+		d := b.Delay(1)
+		_assert(d >= 100*time.Millisecond && d <= 300*time.Millisecond, "delay out of jitter range: %s", d)
+	}
+
+	t.Run("no max", func(t *testing.T) {
+		b := Backoff{Base: 100 * time.Millisecond}
+		_assert(b.Delay(40) == maxBackoffDelay, "expect the delay to be bounded, but got %s", b.Delay(40))
+		b.Jitter = 1
+		for i := 0; i < 10; i++ {
+			d := b.Delay(100)
+			_assert(d >= 0 && d <= maxBackoffDelay, "delay out of range: %s", d)
+		}
+	})
+	t.Run("defaults", func(t *testing.T) {
+		b := Backoff{Base: time.Second}.withDefaults()
+		_assert(b.Base == time.Second && b.Max == DefaultBackoff.Max && b.Factor == DefaultBackoff.Factor,
+			"expect zero fields from DefaultBackoff, but got %+v", b)
+	})
+}
+
+func TestReconnectingClient(t *testing.T) {
+	var foo Foo
+	server := NewServer()
+	_ = server.Register(&foo)
+	inner, _ := net.Listen("tcp", "127.0.0.1:0")
+	addr := inner.Addr().String()
+	l := &trackingListener{Listener: inner}
+	go server.Accept(l)
+
+	var mu sync.Mutex
+	var states []ConnState
+	client, err := NewReconnectingClient("tcp", addr, nil, &ReconnectOption{
+		Backoff: Backoff{Base: 50 * time.Millisecond, Max: 200 * time.Millisecond},
+		OnStateChange: func(old, new ConnState) {
+			mu.Lock()
+			states = append(states, new)
+			mu.Unlock()
+		},
+	})
+	_assert(err == nil, "NewReconnectingClient() error:%v", err)
+	defer client.Close()
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	var reply int
+	err = client.Call(ctx, "Foo.Sum", &Args{Num1: 1, Num2: 2}, &reply)
+	_assert(err == nil && reply == 3, "call Foo.Sum error:%v reply:%d", err, reply)
+
+	t.Run("connection broken", func(t *testing.T) {
+		l.breakConns()
+		time.Sleep(100 * time.Millisecond)
+		err := client.Call(ctx, "Foo.Sum", &Args{Num1: 2, Num2: 3}, &reply)
+		_assert(err == nil && reply == 5, "call Foo.Sum error:%v reply:%d", err, reply)
+	})
+	t.Run("server restarted", func(t *testing.T) {
+		_ = l.Close()
+		l.breakConns()
+		go func() {
+			time.Sleep(300 * time.Millisecond)
+			inner, err := net.Listen("tcp", addr)
+			if err != nil {
+				return
+			}
+			server.Accept(inner)
+		}()
+		time.Sleep(100 * time.Millisecond)
+		_assert(client.State() != Connected, "expect client to be disconnected")
+		// the call waits until reconnected
+		err := client.Call(ctx, "Foo.Sum", &Args{Num1: 3, Num2: 4}, &reply)
+		_assert(err == nil && reply == 7, "call Foo.Sum error:%v reply:%d", err, reply)
+	})
+
+	_ = client.Close()
+	_assert(client.State() == Closed, "expect state closed, but got %s", client.State())
+	mu.Lock()
+	defer mu.Unlock()
+	_assert(len(states) >= 4 && states[0] == Connected && states[len(states)-1] == Closed,
+		"unexpected state changes: %v", states)
+}
+
+func TestReconnectingClient_CloseWhileWaiting(t *testing.T) {
+	l, _ := net.Listen("tcp", "127.0.0.1:0")
+	addr := l.Addr().String()
+	_ = l.Close()
+	var mu sync.Mutex
+	var states []ConnState
+	client, _ := NewReconnectingClient("tcp", addr, nil, &ReconnectOption{
+		Backoff: Backoff{Base: 100 * time.Millisecond, Jitter: 0.01},
+		OnStateChange: func(old, new ConnState) {
+			mu.Lock()
+			states = append(states, new)
+			mu.Unlock()
+		},
+	})
+	time.Sleep(50 * time.Millisecond)
+	_assert(client.State() == Disconnected, "expect state disconnected, but got %s", client.State())
+	_ = client.Close()
+	time.Sleep(200 * time.Millisecond)
+	mu.Lock()
+	defer mu.Unlock()
+	_assert(states[len(states)-1] == Closed, "expect no state change once closed: %v", states)
+}
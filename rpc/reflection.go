@@ -0,0 +1,123 @@
+package rpc
+
+import (
+	"errors"
+	"reflect"
+	"sort"
+)
+
+// TypeDescriptor is a machine-readable description of a Go type,
+// so that generic clients can build arguments and decode replies without compiled stubs.
+type TypeDescriptor struct {
+	Kind      string            // kind of the type as reflect.Kind prints it, e.g. "int", "struct", "ptr", "slice", "map"
+	Name      string            // name of a named type with its package, e.g. "rpc.Args", "" if unnamed
+	Elem      *TypeDescriptor   // element type of ptr, slice, array and map
+	Key       *TypeDescriptor   // key type of map
+	Len       int               // length of array
+	Fields    []FieldDescriptor // exported fields of struct, not set if Recursive
+	Recursive bool              // a named type already being described, as in recursive types, only Kind and Name are set
+}
+
+// FieldDescriptor describes a field of a struct.
+type FieldDescriptor struct {
+	Name string
+	Tag  string // struct tag, e.g. `json:"name"`
+	Type *TypeDescriptor
+}
+
+// MethodDescriptor describes a method of a service.
+type MethodDescriptor struct {
+	Name      string
+	ArgType   *TypeDescriptor
+	ReplyType *TypeDescriptor
+}
+
+// ServiceDescriptor describes a registered service.
+type ServiceDescriptor struct {
+	Name    string
+	Version string             // "" if unversioned
+	Methods []MethodDescriptor // sorted by name
+}
+
+// Reflection is the built-in reflection service, which lists the services of the server and describes their methods.
+type Reflection struct {
+	server *Server
+}
+
+// RegisterReflection registers the Reflection service on the server.
+func (server *Server) RegisterReflection() error {
+	return server.Register(&Reflection{server: server})
+}
+
+// RegisterReflection registers the Reflection service on the DefaultServer.
+func RegisterReflection() error { return DefaultServer.RegisterReflection() }
+
+// ListServices replies the names of the registered services, sorted. The argument is ignored.
+func (r *Reflection) ListServices(_ string, names *[]string) error {
+	r.server.serviceMap.Range(func(namei, _ interface{}) bool {
+		*names = append(*names, namei.(string))
+		return true
+	})
+	sort.Strings(*names)
+	return nil
+}
+
+// DescribeService replies the description of the named service, of the version calls without a version constraint reach.
+func (r *Reflection) DescribeService(name string, desc *ServiceDescriptor) error {
+	vsi, ok := r.server.serviceMap.Load(name)
+	if !ok {
+		return errors.New("rpc server: can't find service " + name)
+	}
+	svc := vsi.(*serviceVersions).find("")
+	desc.Name = svc.name
+	desc.Version = svc.version
+	desc.Methods = make([]MethodDescriptor, 0, len(svc.method))
+	for name, mType := range svc.method {
+		desc.Methods = append(desc.Methods, MethodDescriptor{
+			Name:      name,
+			ArgType:   DescribeType(mType.ArgType),
+			ReplyType: DescribeType(mType.ReplyType),
+		})
+	}
+	sort.Slice(desc.Methods, func(i, j int) bool { return desc.Methods[i].Name < desc.Methods[j].Name })
+	return nil
+}
+
+// DescribeType returns the description of t.
+func DescribeType(t reflect.Type) *TypeDescriptor {
+	return describeType(t, make(map[reflect.Type]bool))
+}
+
+// describeType describes t, describing is the set of named types being described.
+func describeType(t reflect.Type, describing map[reflect.Type]bool) *TypeDescriptor {
+	d := &TypeDescriptor{Kind: t.Kind().String()}
+	if t.Name() != "" {
+		d.Name = t.String()
+		if describing[t] {
+			d.Recursive = true
+			return d
+		}
+		describing[t] = true
+		defer delete(describing, t)
+	}
+	switch t.Kind() {
+	case reflect.Pointer, reflect.Slice:
+		d.Elem = describeType(t.Elem(), describing)
+	case reflect.Array:
+		d.Elem = describeType(t.Elem(), describing)
+		d.Len = t.Len()
+	case reflect.Map:
+		d.Key = describeType(t.Key(), describing)
+		d.Elem = describeType(t.Elem(), describing)
+	case reflect.Struct:
+		d.Fields = make([]FieldDescriptor, 0, t.NumField())
+		for i := 0; i < t.NumField(); i++ {
+			f := t.Field(i)
+			if !f.IsExported() {
+				continue
+			}
+			d.Fields = append(d.Fields, FieldDescriptor{Name: f.Name, Tag: string(f.Tag), Type: describeType(f.Type, describing)})
+		}
+	}
+	return d
+}
@@ -0,0 +1,59 @@
+package rpc
+
+import (
+	"context"
+	"net"
+	"reflect"
+	"testing"
+)
+
+type tree map[string]tree
+
+type list []list
+
+type node struct {
+	Value    int `json:"value"`
+	Children []*node
+	parent   *node
+}
+
+func TestReflection(t *testing.T) {
+	var foo Foo
+	server := NewServer()
+	_ = server.Register(&foo)
+	_ = server.RegisterReflection()
+	l, _ := net.Listen("tcp", "127.0.0.1:0")
+	defer l.Close()
+	go server.Accept(l)
+	client, err := Dial("tcp", l.Addr().String())
+	_assert(err == nil, "Dial() error:%v", err)
+	defer client.Close()
+
+	var names []string
+	err = client.Call(context.Background(), "Reflection.ListServices", "", &names)
+	_assert(err == nil && reflect.DeepEqual(names, []string{"Foo", "Reflection"}), "ListServices error:%v names:%v", err, names)
+
+	var desc ServiceDescriptor
+	err = client.Call(context.Background(), "Reflection.DescribeService", "Foo", &desc)
+	_assert(err == nil && desc.Name == "Foo" && len(desc.Methods) == 2, "DescribeService error:%v desc:%+v", err, desc)
+	sum := desc.Methods[1]
+	_assert(sum.Name == "Sum", "expect methods sorted, but got %s", sum.Name)
+	_assert(sum.ArgType.Kind == "struct" && sum.ArgType.Name == "rpc.Args" && len(sum.ArgType.Fields) == 2 &&
+		sum.ArgType.Fields[0].Name == "Num1" && sum.ArgType.Fields[0].Type.Kind == "int", "unexpected arg type: %+v", sum.ArgType)
+	_assert(sum.ReplyType.Kind == "ptr" && sum.ReplyType.Elem.Kind == "int", "unexpected reply type: %+v", sum.ReplyType)
+
+	err = client.Call(context.Background(), "Reflection.DescribeService", "Bar", &desc)
+	_assert(err != nil, "expect an error for an unknown service")
+
+	t.Run("recursive type", func(t *testing.T) {
+		d := DescribeType(reflect.TypeOf(node{}))
+		_assert(!d.Recursive && len(d.Fields) == 2 && d.Fields[0].Tag == `json:"value"`, "unexpected fields: %+v", d.Fields)
+		child := d.Fields[1].Type.Elem.Elem
+		_assert(child.Kind == "struct" && child.Name == "rpc.node" && child.Recursive, "unexpected recursive field: %+v", child)
+
+		d = DescribeType(reflect.TypeOf(tree{}))
+		_assert(!d.Recursive && d.Key.Kind == "string" && d.Elem.Name == "rpc.tree" && d.Elem.Recursive, "unexpected map: %+v", d)
+		d = DescribeType(reflect.TypeOf(list{}))
+		_assert(!d.Recursive && d.Elem.Name == "rpc.list" && d.Elem.Recursive, "unexpected slice: %+v", d)
+	})
+}
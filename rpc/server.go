@@ -1,10 +1,13 @@
 package rpc
 
 import (
+	"bufio"
+	"bytes"
 	"encoding/json"
 	"errors"
 	"fmt"
 	"github.com/GallifreyGoTutoural/ggt-rpc/codec"
+	"github.com/GallifreyGoTutoural/ggt-rpc/version"
 	"io"
 	"log"
 	"net"
@@ -12,6 +15,7 @@ import (
 	"reflect"
 	"strings"
 	"sync"
+	"sync/atomic"
 	"time"
 )
 
@@ -22,6 +26,10 @@ type Option struct {
 	CodecType            codec.Type    // client may choose different Codec to encode body
 	ConnectionTimeoutSec time.Duration // 0 means no limit
 	HandleTimeoutSec     time.Duration // 0 means no limit
+	HeartbeatInterval    time.Duration // interval of pings, 0 means no heartbeat
+	HeartbeatTimeout     time.Duration // close the connection if nothing is received within it, 0 means 3 intervals
+	MaxHeaderSize        int           // max size in bytes of response headers, 0 means no limit
+	MaxBodySize          int           // max size in bytes of response bodies, 0 means no limit
 }
 
 var DefaultOption = &Option{
@@ -32,9 +40,10 @@ var DefaultOption = &Option{
 }
 
 const (
-	connected        = "200 Connected to ggtrpc"
-	defaultRPCPath   = "/_ggtrpc_"
-	defaultDebugPath = "/debug/ggtrpc"
+	connected          = "200 Connected to ggtrpc"
+	defaultRPCPath     = "/_ggtrpc_"
+	defaultDebugPath   = "/debug/ggtrpc"
+	defaultJSONRPCPath = "/_ggtrpc_/jsonrpc"
 )
 
 func (server *Server) ServeHTTP(w http.ResponseWriter, r *http.Request) {
@@ -56,7 +65,11 @@ func (server *Server) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 func (server *Server) HandleHTTP() {
 	http.Handle(defaultRPCPath, server)
 	http.Handle(defaultDebugPath, debugHTTP{server})
+	http.Handle(defaultJSONRPCPath, server.JSONRPCHandler())
+	http.Handle(defaultHealthPath, healthHTTP{server})
 	log.Println("rpc server debug path:", defaultDebugPath)
+	log.Println("rpc server json-rpc path:", defaultJSONRPCPath)
+	log.Println("rpc server health path:", defaultHealthPath)
 }
 
 // HandleHTTP registers an HTTP handler for RPC messages on rpcPath, and a debugging handler on debugPath
@@ -66,7 +79,25 @@ func HandleHTTP() {
 
 // Server represents an RPC Server.
 type Server struct {
-	serviceMap sync.Map
+	// HeartbeatInterval and HeartbeatTimeout configure the heartbeat of connections,
+	// both sides send pings and close the connection if nothing is received within the timeout.
+	// 0 means using the values of the client's Option.
+	HeartbeatInterval time.Duration
+	HeartbeatTimeout  time.Duration
+	// IdleTimeout closes connections without requests for this long, and MaxConnectionAge closes connections
+	// that have lived for this long, so that clients reconnect and load rebalances across servers.
+	// Clients are told to go away first, and requests already sent are handled within MaxConnectionAgeGrace.
+	// 0 means no limit, except for MaxConnectionAgeGrace where it means 10s.
+	IdleTimeout           time.Duration
+	MaxConnectionAge      time.Duration
+	MaxConnectionAgeGrace time.Duration
+	// MaxHeaderSize and MaxBodySize are the max sizes in bytes of request headers and bodies.
+	// An oversize body is skipped and answered with ErrMessageTooLarge, an oversize header closes the connection.
+	// 0 means no limit, except for HTTP request bodies which are limited to defaultMaxHTTPBodySize.
+	MaxHeaderSize int
+	MaxBodySize   int
+	serviceMap    sync.Map               // name -> *serviceVersions
+	health        atomic.Pointer[Health] // the registered Health service, served over HTTP
 }
 
 // NewServer returns a new Server.
@@ -96,15 +127,36 @@ func Accept(lis net.Listener) {
 
 // ServerConn runs the server on a single connection.
 // ServerConn blocks, serving the connection until the client hangs up.
+// Connections from net/rpc clients using the gob codec are served as well.
 func (server *Server) ServerConn(conn io.ReadWriteCloser) {
 	defer func() {
 		_ = conn.Close()
 	}()
+	// a ggtrpc connection starts with the JSON options, otherwise it's a net/rpc gob connection
+	br := bufio.NewReader(conn)
+	first, err := br.Peek(1)
+	if err != nil {
+		if err != io.EOF {
+			log.Printf("rpc server: read connection error: %v", err)
+		}
+		return
+	}
+	conn = &bufferedConn{ReadWriteCloser: conn, r: br}
+	if first[0] != '{' {
+		server.serveCodec(codec.NewGobCodec(conn), netRPCOption)
+		return
+	}
 	var opt Option
-	if err := json.NewDecoder(conn).Decode(&opt); err != nil {
+	dec := json.NewDecoder(conn)
+	if err := dec.Decode(&opt); err != nil {
 		log.Printf("rpc server: options error: %v", err)
 		return
 	}
+	// the decoder may have read ahead into the codec stream, so read what it buffered first,
+	// skipping the newline that terminates the options
+	buffered, _ := io.ReadAll(dec.Buffered())
+	buffered = bytes.TrimLeft(buffered, " \t\r\n")
+	conn = &bufferedConn{ReadWriteCloser: conn, r: io.MultiReader(bytes.NewReader(buffered), conn)}
 	if opt.MagicNumber != MagicNumber {
 		log.Printf("rpc server: invalid magic number %x", opt.MagicNumber)
 		return
@@ -114,27 +166,79 @@ func (server *Server) ServerConn(conn io.ReadWriteCloser) {
 		log.Printf("rpc server: invalid codec type %s", opt.CodecType)
 		return
 	}
+	if server.HeartbeatInterval > 0 {
+		opt.HeartbeatInterval = server.HeartbeatInterval
+	}
+	if server.HeartbeatTimeout > 0 {
+		opt.HeartbeatTimeout = server.HeartbeatTimeout
+	}
 	server.serveCodec(f(conn), &opt)
 
 }
 
+// netRPCOption is the option of connections from net/rpc clients, which don't send options.
+var netRPCOption = &Option{CodecType: codec.GobType}
+
+// bufferedConn is a connection whose reads are served by r, which may hold data already read from the connection.
+type bufferedConn struct {
+	io.ReadWriteCloser
+	r io.Reader
+}
+
+func (c *bufferedConn) Read(p []byte) (int, error) {
+	return c.r.Read(p)
+}
+
 var invalidRequest = struct{}{}
 
 func (server *Server) serveCodec(cc codec.Codec, option *Option) {
 	sending := new(sync.Mutex) // make sure to send a complete response
 	wg := new(sync.WaitGroup)  // wait until all request are handled
+	if limiter, ok := cc.(codec.SizeLimiter); ok {
+		limiter.SetMaxSize(server.MaxHeaderSize, server.MaxBodySize)
+	}
+	lastRecv := time.Now().UnixNano()
+	done := make(chan struct{})
+	defer close(done)
+	if option.HeartbeatInterval > 0 {
+		timeout := heartbeatTimeout(option.HeartbeatInterval, option.HeartbeatTimeout)
+		go heartbeat(cc, sending, &lastRecv, option.HeartbeatInterval, timeout, done)
+	}
+	activity := &connActivity{lastActive: lastRecv}
+	if server.IdleTimeout > 0 || server.MaxConnectionAge > 0 {
+		go server.watchLifetime(cc, sending, activity, done)
+	}
 
 	for {
 		req, err := server.readRequest(cc)
+		if req != nil {
+			atomic.StoreInt64(&lastRecv, time.Now().UnixNano())
+		}
 		if err != nil {
 			if req == nil {
 				break // it's not possible to recover, so close the connection
 			}
+			req.h.Error = fmt.Sprint(err)
 			server.sendResponse(cc, req.h, invalidRequest, sending)
 			continue
 		}
+		if isPing(req.h) {
+			server.sendResponse(cc, &codec.Header{ServiceMethod: pongMethod, Seq: req.h.Seq}, controlBody, sending)
+			continue
+		}
+		if isPong(req.h) {
+			continue
+		}
+		if isGoAway(req.h) {
+			atomic.StoreInt32(&activity.goneAway, 1)
+			continue
+		}
 		wg.Add(1)
-		go server.handleRequest(cc, req, sending, wg, option.HandleTimeoutSec)
+		activity.begin()
+		go func(req *request) {
+			defer activity.end()
+			server.handleRequest(cc, req, sending, wg, option.HandleTimeoutSec)
+		}(req)
 
 	}
 	wg.Wait()
@@ -165,21 +269,23 @@ func (server *Server) readRequest(cc codec.Codec) (*request, interface{}) {
 		return nil, err
 	}
 	req := &request{h: h}
+	if isPing(h) || isPong(h) || isGoAway(h) {
+		return req, cc.ReadBody(nil)
+	}
 
-	req.svc, req.mtype, err = server.findService(h.ServiceMethod)
+	req.svc, req.mtype, err = server.findService(h.ServiceMethod, h.Metadata[version.MetadataKey])
+	// the header is sent back in the response, without the metadata
+	h.Metadata = nil
 	if err != nil {
+		// discard the body, so that the next request can be read
+		_ = cc.ReadBody(nil)
 		return req, err
 	}
 	req.argv = req.mtype.newArgv()
 	req.replyv = req.mtype.newReplyv()
 
-	// make sure argv is a pointer, read request body to argv
-	argvi := req.argv.Interface()
-	if req.argv.Type().Kind() != reflect.Pointer {
-		argvi = req.argv.Addr().Interface()
-	}
-
-	if err = cc.ReadBody(argvi); err != nil {
+	// read request body to argv
+	if err = cc.ReadBody(argvPointer(req.argv)); err != nil {
 		log.Println("rpc server: read argv err:", err)
 		return req, err
 	}
@@ -187,6 +293,35 @@ func (server *Server) readRequest(cc codec.Codec) (*request, interface{}) {
 
 }
 
+// defaultMaxHTTPBodySize is the max size in bytes of HTTP request bodies when MaxBodySize is 0.
+const defaultMaxHTTPBodySize = 1 << 20
+
+// limitBody limits the body of an HTTP request to MaxBodySize.
+func (server *Server) limitBody(w http.ResponseWriter, req *http.Request) io.ReadCloser {
+	n := server.MaxBodySize
+	if n <= 0 {
+		n = defaultMaxHTTPBodySize
+	}
+	return http.MaxBytesReader(w, req.Body, int64(n))
+}
+
+// bodyErrorStatus returns the HTTP status of an error reading a request body.
+func bodyErrorStatus(err error) int {
+	var maxBytesErr *http.MaxBytesError
+	if errors.As(err, &maxBytesErr) {
+		return http.StatusRequestEntityTooLarge
+	}
+	return http.StatusBadRequest
+}
+
+// argvPointer makes sure argv is a pointer, so that it can be decoded into.
+func argvPointer(argv reflect.Value) interface{} {
+	if argv.Type().Kind() != reflect.Pointer {
+		return argv.Addr().Interface()
+	}
+	return argv.Interface()
+}
+
 func (server *Server) sendResponse(cc codec.Codec, h *codec.Header, body interface{}, sending *sync.Mutex) {
 	sending.Lock()
 	defer sending.Unlock()
@@ -241,11 +376,86 @@ func (server *Server) handleRequest(cc codec.Codec, req *request, sending *sync.
 // - the second argument is a pointer
 // - one return value, of type error
 func (server *Server) Register(rcvr interface{}) error {
-	s := newService(rcvr)
-	// if service already exist, return error
-	// otherwise, store the service
-	if _, dup := server.serviceMap.LoadOrStore(s.name, s); dup {
-		return errors.New("rpc: service already defined: " + s.name)
+	return server.register(newService(rcvr))
+}
+
+// RegisterName is like Register but uses the provided name for the service instead of the receiver's type name,
+// e.g. to register two instances of a type, or versions of a service.
+func (server *Server) RegisterName(name string, rcvr interface{}) error {
+	if name == "" || strings.Contains(name, ".") {
+		return errors.New("rpc: invalid service name: " + name)
+	}
+	return server.register(newNamedService(name, rcvr))
+}
+
+// RegisterVersion is like RegisterName and registers the service with a version, such as "1.2.0",
+// next to its other versions. An empty name means the receiver's type name.
+func (server *Server) RegisterVersion(name, version string, rcvr interface{}) error {
+	if name == "" {
+		name = reflect.Indirect(reflect.ValueOf(rcvr)).Type().Name()
+	}
+	if name == "" || strings.Contains(name, ".") {
+		return errors.New("rpc: invalid service name: " + name)
+	}
+	s := newNamedService(name, rcvr)
+	s.version = version
+	return server.register(s)
+}
+
+// VersionMetadata returns the versions of the versioned services keyed by version.Key, separated by spaces,
+// to be published as server metadata, e.g. with registry.HeartbeatWithMetadata.
+func (server *Server) VersionMetadata() map[string]string {
+	md := make(map[string]string)
+	server.serviceMap.Range(func(namei, vsi interface{}) bool {
+		var versions []string
+		for _, svc := range vsi.(*serviceVersions).services {
+			if svc.version != "" {
+				versions = append(versions, svc.version)
+			}
+		}
+		if len(versions) > 0 {
+			md[version.Key(namei.(string))] = strings.Join(versions, " ")
+		}
+		return true
+	})
+	return md
+}
+
+func (server *Server) register(s *service) error {
+	// if the version of the service already exists, return error
+	// otherwise, store the service next to its other versions
+	for {
+		vsi, loaded := server.serviceMap.LoadOrStore(s.name, &serviceVersions{services: []*service{s}})
+		if !loaded {
+			return nil
+		}
+		vs := vsi.(*serviceVersions)
+		if vs.get(s.version) != nil {
+			if s.version != "" {
+				return errors.New("rpc: service already defined: " + s.name + " version " + s.version)
+			}
+			return errors.New("rpc: service already defined: " + s.name)
+		}
+		if server.serviceMap.CompareAndSwap(s.name, vsi, vs.with(s)) {
+			return nil
+		}
+	}
+}
+
+// Unregister removes the named service, all of its versions. New requests to it fail at once,
+// and Unregister returns once the calls in flight have completed.
+func (server *Server) Unregister(name string) error {
+	vsi, ok := server.serviceMap.LoadAndDelete(name)
+	if !ok {
+		return errors.New("rpc: can't find service " + name)
+	}
+	for _, svc := range vsi.(*serviceVersions).services {
+		if svc.rcvr.IsValid() {
+			if h, ok := svc.rcvr.Interface().(*Health); ok {
+				server.health.CompareAndSwap(h, nil)
+			}
+		}
+		svc.calls.drain()
 	}
 	return nil
 }
@@ -253,8 +463,11 @@ func (server *Server) Register(rcvr interface{}) error {
 // Register publishes the receiver's methods in the DefaultServer.
 func Register(rcvr interface{}) error { return DefaultServer.Register(rcvr) }
 
-// findService looks up the request service.
-func (server *Server) findService(serviceMethod string) (svc *service, mType *methodType, err error) {
+// RegisterName publishes the receiver's methods in the DefaultServer under the name.
+func RegisterName(name string, rcvr interface{}) error { return DefaultServer.RegisterName(name, rcvr) }
+
+// findService looks up the request service, the newest version matching constraint.
+func (server *Server) findService(serviceMethod, constraint string) (svc *service, mType *methodType, err error) {
 
 	dot := strings.LastIndex(serviceMethod, ".")
 	if dot < 0 {
@@ -265,12 +478,16 @@ func (server *Server) findService(serviceMethod string) (svc *service, mType *me
 	serviceName, methodName := serviceMethod[:dot], serviceMethod[dot+1:]
 
 	// get service
-	svci, ok := server.serviceMap.Load(serviceName)
+	vsi, ok := server.serviceMap.Load(serviceName)
 	if !ok {
 		err = errors.New("rpc server: can't find service " + serviceName)
 		return
 	}
-	svc = svci.(*service)
+	svc = vsi.(*serviceVersions).find(constraint)
+	if svc == nil {
+		err = fmt.Errorf("rpc server: can't find service %s of version %q", serviceName, constraint)
+		return
+	}
 
 	// get method
 	mType = svc.method[methodName]
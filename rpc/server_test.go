@@ -0,0 +1,114 @@
+package rpc
+
+import (
+	"context"
+	"github.com/GallifreyGoTutoural/ggt-rpc/version"
+	"net"
+	"testing"
+	"time"
+)
+
+// Gate answers calls once it's opened.
+type Gate chan struct{}
+
+func (g Gate) Wait(n int, reply *int) error {
+	<-g
+	*reply = n
+	return nil
+}
+
+func TestServer_RegisterName(t *testing.T) {
+	var foo Foo
+	server := NewServer()
+	_assert(server.RegisterName("Foo.v1", &foo) != nil, "expect an error for a name with a dot")
+	_assert(server.RegisterName("FooV1", &foo) == nil && server.RegisterName("FooV2", &foo) == nil, "RegisterName() failed")
+	_assert(server.RegisterName("FooV1", &foo) != nil, "expect an error for a duplicate name")
+	_, _, err := server.findService("FooV2.Sum", "")
+	_assert(err == nil, "findService() error:%v", err)
+	_, _, err = server.findService("Foo.Sum", "")
+	_assert(err != nil, "expect the type name not to be registered")
+}
+
+func TestServer_Unregister(t *testing.T) {
+	gate := make(Gate)
+	server := NewServer()
+	_ = server.RegisterName("Gate", gate)
+	l, _ := net.Listen("tcp", "127.0.0.1:0")
+	defer l.Close()
+	go server.Accept(l)
+	client, err := Dial("tcp", l.Addr().String())
+	_assert(err == nil, "Dial() error:%v", err)
+	defer client.Close()
+
+	var reply int
+	call := client.Go("Gate.Wait", 1, &reply, make(chan *Call, 1))
+	time.Sleep(50 * time.Millisecond)
+	unregistered := make(chan error)
+	go func() { unregistered <- server.Unregister("Gate") }()
+	select {
+	case <-unregistered:
+		t.Fatal("expect Unregister to wait for the call in flight")
+	case <-time.After(50 * time.Millisecond):
+	}
+	err = client.Call(context.Background(), "Gate.Wait", 2, &reply)
+	_assert(err != nil, "expect new calls to fail once unregistering")
+
+	close(gate)
+	_assert(<-unregistered == nil, "Unregister() failed")
+	<-call.Done
+	_assert(call.Error == nil && reply == 1, "expect the call in flight to complete, error:%v reply:%d", call.Error, reply)
+	_assert(server.Unregister("Gate") != nil, "expect an error for an unknown service")
+	_assert(server.RegisterName("Gate", gate) == nil, "expect the name to be free again")
+}
+
+func TestServer_RegisterVersion(t *testing.T) {
+	var foo Foo
+	server := NewServer()
+	_assert(server.RegisterVersion("", "1.2", &foo) == nil, "RegisterVersion() failed")
+	md := server.VersionMetadata()
+	_assert(len(md) == 1 && md[version.Key("Foo")] == "1.2", "wrong version metadata: %v", md)
+	l, _ := net.Listen("tcp", "127.0.0.1:0")
+	defer l.Close()
+	go server.Accept(l)
+	client, err := Dial("tcp", l.Addr().String())
+	_assert(err == nil, "Dial() error:%v", err)
+	defer client.Close()
+
+	for constraint, ok := range map[string]bool{"": true, "1": true, "1.2": true, "1.20": false, "2": false} {
+		var reply int
+		err := client.Call(WithVersion(context.Background(), constraint), "Foo.Sum", Args{Num1: 1, Num2: 2}, &reply)
+		_assert((err == nil) == ok, "constraint %q: call error:%v", constraint, err)
+		_assert(!ok || reply == 3, "constraint %q: wrong reply %d", constraint, reply)
+	}
+
+	t.Run("side by side", func(t *testing.T) {
+		server := NewServer()
+		_assert(server.RegisterVersion("Release", "1.2", Release("v1")) == nil, "RegisterVersion() failed")
+		_assert(server.RegisterVersion("Release", "2.0", Release("v2")) == nil, "RegisterVersion() failed")
+		_assert(server.RegisterVersion("Release", "2.0", Release("v2")) != nil, "expect an error for a duplicate version")
+		md := server.VersionMetadata()
+		_assert(md[version.Key("Release")] == "1.2 2.0", "wrong version metadata: %v", md)
+		l, _ := net.Listen("tcp", "127.0.0.1:0")
+		defer l.Close()
+		go server.Accept(l)
+		client, err := Dial("tcp", l.Addr().String())
+		_assert(err == nil, "Dial() error:%v", err)
+		defer client.Close()
+
+		for constraint, want := range map[string]string{"": "v2", "1": "v1", "<2": "v1", ">=1.2": "v2", "2.x": "v2", "3": ""} {
+			var reply string
+			err := client.Call(WithVersion(context.Background(), constraint), "Release.Read", 0, &reply)
+			_assert((err == nil) == (want != ""), "constraint %q: call error:%v", constraint, err)
+			_assert(reply == want, "constraint %q: expect %q, but got %q", constraint, want, reply)
+		}
+		_assert(server.Unregister("Release") == nil && len(server.VersionMetadata()) == 0, "expect every version to be unregistered")
+	})
+}
+
+// Release replies its value, to tell versions apart.
+type Release string
+
+func (r Release) Read(_ int, reply *string) error {
+	*reply = string(r)
+	return nil
+}
@@ -1,14 +1,19 @@
 package rpc
 
 import (
+	"errors"
+	"github.com/GallifreyGoTutoural/ggt-rpc/version"
 	"go/ast"
 	"log"
 	"reflect"
+	"sort"
+	"sync"
 	"sync/atomic"
 )
 
 type methodType struct {
 	method    reflect.Method
+	fn        reflect.Value // function registered by HandleFunc, called instead of method if valid
 	ArgType   reflect.Type
 	ReplyType reflect.Type
 	numCalls  uint64
@@ -44,17 +49,97 @@ func (m *methodType) newReplyv() reflect.Value {
 }
 
 type service struct {
-	name   string                 // name of service
-	typ    reflect.Type           // type of service
-	rcvr   reflect.Value          // receiver of methods for the service
-	method map[string]*methodType // registered methods
+	name    string                 // name of service
+	version string                 // version of service, "" if unversioned
+	typ     reflect.Type           // type of service
+	rcvr    reflect.Value          // receiver of methods for the service
+	method  map[string]*methodType // registered methods
+	calls   *inflight              // calls in flight, shared by the copies of the service made by HandleFunc
+}
+
+// serviceVersions are the services registered under a name, one per version, "" for the unversioned one.
+// They're replaced rather than modified, so that requests look services up without locks.
+type serviceVersions struct {
+	services []*service // sorted by version, the newest last
+}
+
+// find returns the newest service whose version matches constraint,
+// the unversioned one first when there is no constraint, nil if none matches.
+func (v *serviceVersions) find(constraint string) *service {
+	if constraint == "" {
+		if s := v.get(""); s != nil {
+			return s
+		}
+	}
+	for i := len(v.services) - 1; i >= 0; i-- {
+		if s := v.services[i]; version.Matches(s.version, constraint) {
+			return s
+		}
+	}
+	return nil
+}
+
+// get returns the service of the version, nil if there is none.
+func (v *serviceVersions) get(version string) *service {
+	for _, s := range v.services {
+		if s.version == version {
+			return s
+		}
+	}
+	return nil
+}
+
+// with returns a copy of v with s added, replacing the service of the same version.
+func (v *serviceVersions) with(s *service) *serviceVersions {
+	services := make([]*service, 0, len(v.services)+1)
+	for _, old := range v.services {
+		if old.version != s.version {
+			services = append(services, old)
+		}
+	}
+	services = append(services, s)
+	sort.SliceStable(services, func(i, j int) bool {
+		return version.Compare(services[i].version, services[j].version) < 0
+	})
+	return &serviceVersions{services: services}
+}
+
+// inflight tracks the calls of a service, so that Unregister can wait for them.
+type inflight struct {
+	mu      sync.RWMutex
+	removed bool
+	wg      sync.WaitGroup
+}
+
+// begin records a call, it returns false if the service has been unregistered.
+func (c *inflight) begin() bool {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	if c.removed {
+		return false
+	}
+	c.wg.Add(1)
+	return true
+}
+
+// drain refuses new calls and waits for the calls in flight.
+func (c *inflight) drain() {
+	c.mu.Lock()
+	c.removed = true
+	c.mu.Unlock()
+	c.wg.Wait()
 }
 
 func newService(rcvr interface{}) *service {
+	return newNamedService(reflect.Indirect(reflect.ValueOf(rcvr)).Type().Name(), rcvr)
+}
+
+func newNamedService(name string, rcvr interface{}) *service {
 	s := new(service)
 	s.rcvr = reflect.ValueOf(rcvr)
-	s.name = reflect.Indirect(s.rcvr).Type().Name()
+	s.name = name
 	s.typ = reflect.TypeOf(rcvr)
+	s.calls = new(inflight)
 	s.method = make(map[string]*methodType)
 	// register all methods
 	s.registerMethods()
@@ -96,7 +181,19 @@ func isExportedOrBuiltinType(t reflect.Type) bool {
 }
 
 func (s *service) call(m *methodType, argv, rplyv reflect.Value) error {
+	if !s.calls.begin() {
+		return errors.New("rpc server: service unregistered: " + s.name)
+	}
+	defer s.calls.wg.Done()
 	atomic.AddUint64(&m.numCalls, 1)
+	if m.fn.IsValid() {
+		ret := m.fn.Call([]reflect.Value{argv})
+		if errInter := ret[1].Interface(); errInter != nil {
+			return errInter.(error)
+		}
+		rplyv.Elem().Set(ret[0])
+		return nil
+	}
 	f := m.method.Func
 	ret := f.Call([]reflect.Value{s.rcvr, argv, rplyv})
 	if errInter := ret[0].Interface(); errInter != nil {
@@ -15,7 +15,7 @@ func _assert(cond bool, msg string, v ...interface{}) {
 func TestNewService(t *testing.T) {
 	var foo Foo
 	s := newService(&foo)
-	_assert(s.typ.NumMethod() == 1, "wrong method number, expect 1, but got %d", s.typ.NumMethod())
+	_assert(s.typ.NumMethod() == 2, "wrong method number, expect 2, but got %d", s.typ.NumMethod())
 	mType := s.typ.Method(0).Type
 	_assert(mType != nil, "wrong method type, expect not nil, but got nil")
 }
@@ -0,0 +1,75 @@
+package rpc
+
+import (
+	"context"
+	"errors"
+	"net"
+	"strings"
+	"testing"
+)
+
+type Echo int
+
+func (e Echo) Echo(s string, reply *string) error {
+	*reply = s
+	return nil
+}
+
+func TestMaxMessageSize(t *testing.T) {
+	var echo Echo
+	server := &Server{MaxBodySize: 1024}
+	_ = server.Register(&echo)
+	l, _ := net.Listen("tcp", ":0")
+	defer l.Close()
+	go server.Accept(l)
+	large := strings.Repeat("x", 4096)
+
+	t.Run("server rejects large request", func(t *testing.T) {
+		client, err := Dial("tcp", l.Addr().String())
+		_assert(err == nil, "Dial() error:%v", err)
+		defer client.Close()
+		var reply string
+		err = client.Call(context.Background(), "Echo.Echo", large, &reply)
+		_assert(errors.Is(err, ErrMessageTooLarge), "expect ErrMessageTooLarge, but got %v", err)
+		// the connection is still usable
+		err = client.Call(context.Background(), "Echo.Echo", "hi", &reply)
+		_assert(err == nil && reply == "hi", "call Echo.Echo error:%v reply:%s", err, reply)
+	})
+	t.Run("client rejects large response", func(t *testing.T) {
+		client, err := Dial("tcp", l.Addr().String(), &Option{MaxBodySize: 512})
+		_assert(err == nil, "Dial() error:%v", err)
+		defer client.Close()
+		var reply string
+		err = client.Call(context.Background(), "Echo.Echo", large[:1000], &reply)
+		_assert(errors.Is(err, ErrMessageTooLarge), "expect ErrMessageTooLarge, but got %v", err)
+		err = client.Call(context.Background(), "Echo.Echo", "hi", &reply)
+		_assert(err == nil && reply == "hi", "call Echo.Echo error:%v reply:%s", err, reply)
+	})
+	t.Run("type definitions are not limited", func(t *testing.T) {
+		var foo Foo
+		server := &Server{MaxBodySize: 16}
+		_ = server.Register(&foo)
+		l, _ := net.Listen("tcp", ":0")
+		defer l.Close()
+		go server.Accept(l)
+		client, err := Dial("tcp", l.Addr().String(), &Option{MaxBodySize: 16})
+		_assert(err == nil, "Dial() error:%v", err)
+		defer client.Close()
+		var reply int
+		err = client.Call(context.Background(), "Foo.Sum", &Args{Num1: 1, Num2: 2}, &reply)
+		_assert(err == nil && reply == 3, "call Foo.Sum error:%v reply:%d", err, reply)
+	})
+	t.Run("server closes connection on large header", func(t *testing.T) {
+		server := &Server{MaxHeaderSize: 128}
+		_ = server.Register(&echo)
+		l, _ := net.Listen("tcp", ":0")
+		defer l.Close()
+		go server.Accept(l)
+		client, err := Dial("tcp", l.Addr().String())
+		_assert(err == nil, "Dial() error:%v", err)
+		defer client.Close()
+		var reply string
+		err = client.Call(context.Background(), "Echo."+large, "hi", &reply)
+		_assert(err != nil && !client.IsAvailable(), "expect connection to be closed, but got %v", err)
+	})
+}
@@ -0,0 +1,118 @@
+// Package version matches service versions against version constraints.
+package version
+
+import (
+	"strconv"
+	"strings"
+)
+
+// MetadataKey is the key of the version constraint in request metadata.
+const MetadataKey = "version"
+
+// Key returns the key of the versions of service in server metadata, such as the metadata published to the registry.
+// The value lists the versions separated by spaces.
+func Key(service string) string {
+	return MetadataKey + "." + service
+}
+
+// Matches reports whether version satisfies constraint, a comma-separated list of conditions which all must hold:
+//   - "1.2" or "1.2.x" matches the versions it's a dotted prefix of, e.g. "1.2" and "1.2.3" but not "1.20"
+//   - ">=1.2", ">1.2", "<=1.2", "<1.2" and "=1.2" compare versions component by component, see Compare
+//
+// The empty constraint matches any version, the empty version only matches the empty constraint.
+func Matches(version, constraint string) bool {
+	if strings.TrimSpace(constraint) == "" {
+		return true
+	}
+	if version == "" {
+		return false
+	}
+	for _, cond := range strings.Split(constraint, ",") {
+		if !matches(version, strings.TrimSpace(cond)) {
+			return false
+		}
+	}
+	return true
+}
+
+// AnyMatches reports whether one of versions, separated by spaces as in server metadata, satisfies constraint.
+func AnyMatches(versions, constraint string) bool {
+	for _, version := range strings.Fields(versions) {
+		if Matches(version, constraint) {
+			return true
+		}
+	}
+	return false
+}
+
+func matches(version, cond string) bool {
+	for _, op := range []string{">=", "<=", ">", "<", "="} {
+		if operand, ok := strings.CutPrefix(cond, op); ok {
+			c := Compare(version, strings.TrimSpace(operand))
+			switch op {
+			case ">=":
+				return c >= 0
+			case "<=":
+				return c <= 0
+			case ">":
+				return c > 0
+			case "<":
+				return c < 0
+			default:
+				return c == 0
+			}
+		}
+	}
+	prefix := strings.Split(cond, ".")
+	for len(prefix) > 0 && isWildcard(prefix[len(prefix)-1]) {
+		prefix = prefix[:len(prefix)-1]
+	}
+	parts := strings.Split(version, ".")
+	if len(prefix) > len(parts) {
+		return false
+	}
+	for i := range prefix {
+		if prefix[i] != parts[i] {
+			return false
+		}
+	}
+	return true
+}
+
+func isWildcard(part string) bool {
+	return part == "x" || part == "X" || part == "*"
+}
+
+// Compare compares the versions a and b component by component, numerically if both are numbers,
+// and returns -1, 0 or +1. Missing components count as 0, so "1.2" equals "1.2.0".
+func Compare(a, b string) int {
+	as, bs := strings.Split(a, "."), strings.Split(b, ".")
+	for i := 0; i < len(as) || i < len(bs); i++ {
+		x, y := "0", "0"
+		if i < len(as) {
+			x = as[i]
+		}
+		if i < len(bs) {
+			y = bs[i]
+		}
+		if c := compareComponent(x, y); c != 0 {
+			return c
+		}
+	}
+	return 0
+}
+
+func compareComponent(x, y string) int {
+	m, errX := strconv.Atoi(x)
+	n, errY := strconv.Atoi(y)
+	if errX != nil || errY != nil {
+		return strings.Compare(x, y)
+	}
+	switch {
+	case m < n:
+		return -1
+	case m > n:
+		return 1
+	}
+	return 0
+}
@@ -0,0 +1,41 @@
+package version
+
+import "testing"
+
+func TestMatches(t *testing.T) {
+	cases := []struct {
+		version, constraint string
+		match               bool
+	}{
+		{"1.2.3", "", true},
+		{"", "", true},
+		{"1.2.3", "1", true},
+		{"1.2.3", "1.2", true},
+		{"1.2.3", "1.2.3", true},
+		{"1.20", "1.2", false},
+		{"1.2.3", "2", false},
+		{"", "1", false},
+		{"1.2.3", "1.x", true},
+		{"1.2.3", "1.2.*", true},
+		{"2.0", "1.x", false},
+		{"1.2", ">=1.2", true},
+		{"1.10", ">=1.2", true},
+		{"1.1.9", ">=1.2", false},
+		{"1.2", ">1.2.0", false},
+		{"1.9", ">=1.2, <2", true},
+		{"2.0", ">=1.2, <2", false},
+		{"1.2", "<=1.2", true},
+		{"1.2.0", "=1.2", true},
+	}
+	for _, c := range cases {
+		if Matches(c.version, c.constraint) != c.match {
+			t.Errorf("Matches(%q, %q) != %v", c.version, c.constraint, c.match)
+		}
+	}
+}
+
+func TestAnyMatches(t *testing.T) {
+	if !AnyMatches("1.2 2.0", "2") || AnyMatches("1.2 2.0", "3") || AnyMatches("", "1") {
+		t.Error("AnyMatches() doesn't match any of the versions")
+	}
+}
@@ -0,0 +1,134 @@
+package xclient
+
+import (
+	"context"
+	"errors"
+	"math/rand"
+	"time"
+)
+
+const (
+	ewmaAlpha      = 0.3         // weight of the latest latency in the EWMA
+	failurePenalty = time.Second // min latency recorded for a call failing with a connection error, so that failing fast doesn't attract calls
+)
+
+// serverStats tracks the load of a server from the calls of XClient.
+type serverStats struct {
+	inflight int     // calls in flight
+	latency  float64 // EWMA of the latencies in nanoseconds, 0 before the first call completes
+}
+
+// begin records a call to rpcAddr, the call must be ended by end.
+func (xc *XClient) begin(rpcAddr string) time.Time {
+	xc.statsMu.Lock()
+	_, ok := xc.stats[rpcAddr]
+	xc.statsMu.Unlock()
+	var servers []string
+	fetched := false
+	if !ok {
+		// the new server may have replaced removed ones,
+		// GetAll is called without the lock since it may ask a registry
+		var err error
+		servers, err = xc.d.GetAll()
+		fetched = err == nil
+	}
+	xc.statsMu.Lock()
+	defer xc.statsMu.Unlock()
+	s, ok := xc.stats[rpcAddr]
+	if !ok {
+		if fetched {
+			xc.pruneStats(servers)
+		}
+		s = &serverStats{}
+		xc.stats[rpcAddr] = s
+	}
+	s.inflight++
+	return time.Now()
+}
+
+// pruneStats drops the stats of idle servers not in servers, xc.statsMu must be held.
+func (xc *XClient) pruneStats(servers []string) {
+	alive := make(map[string]bool, len(servers))
+	for _, server := range servers {
+		alive[server] = true
+	}
+	for rpcAddr, s := range xc.stats {
+		if !alive[rpcAddr] && s.inflight == 0 {
+			delete(xc.stats, rpcAddr)
+		}
+	}
+}
+
+// end records the end of a call to rpcAddr made with ctx and begun at start.
+func (xc *XClient) end(rpcAddr string, ctx context.Context, start time.Time, err error) {
+	latency := time.Since(start)
+	if isConnError(err) && latency < failurePenalty {
+		latency = failurePenalty
+	}
+	xc.statsMu.Lock()
+	defer xc.statsMu.Unlock()
+	s := xc.stats[rpcAddr]
+	s.inflight--
+	// the latency of calls cancelled by the caller is unknown
+	if errors.Is(ctx.Err(), context.Canceled) {
+		return
+	}
+	if s.latency == 0 {
+		s.latency = float64(latency)
+	} else {
+		s.latency = ewmaAlpha*float64(latency) + (1-ewmaAlpha)*s.latency
+	}
+}
+
+// p2cServer picks two random servers and selects the one with the lower cost,
+// which is the EWMA latency weighted by the calls in flight.
+func (xc *XClient) p2cServer(servers []string) string {
+	if len(servers) == 1 {
+		return servers[0]
+	}
+	i := rand.Intn(len(servers))
+	j := rand.Intn(len(servers) - 1)
+	if j >= i {
+		j++
+	}
+	xc.statsMu.Lock()
+	defer xc.statsMu.Unlock()
+	if xc.cost(servers[j]) < xc.cost(servers[i]) {
+		return servers[j]
+	}
+	return servers[i]
+}
+
+// cost returns the cost of a call to rpcAddr, xc.statsMu must be held.
+// Servers without latency yet cost nothing, so that they get probed.
+func (xc *XClient) cost(rpcAddr string) float64 {
+	s, ok := xc.stats[rpcAddr]
+	if !ok {
+		return 0
+	}
+	return s.latency * float64(s.inflight+1)
+}
+
+// leastOutstandingServer selects the server with the fewest calls in flight, ties are broken randomly.
+func (xc *XClient) leastOutstandingServer(servers []string) string {
+	xc.statsMu.Lock()
+	defer xc.statsMu.Unlock()
+	var best string
+	least, ties := 0, 0
+	for _, server := range servers {
+		inflight := 0
+		if s, ok := xc.stats[server]; ok {
+			inflight = s.inflight
+		}
+		switch {
+		case best == "" || inflight < least:
+			best, least, ties = server, inflight, 1
+		case inflight == least:
+			ties++
+			if rand.Intn(ties) == 0 {
+				best = server
+			}
+		}
+	}
+	return best
+}
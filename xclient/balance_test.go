@@ -0,0 +1,71 @@
+package xclient
+
+import (
+	"context"
+	"errors"
+	"github.com/GallifreyGoTutoural/ggt-rpc/internal/testutil"
+	. "github.com/GallifreyGoTutoural/ggt-rpc/rpc"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestXClient_P2C(t *testing.T) {
+	slow, fast := &Lookup{delay: 50 * time.Millisecond}, &Lookup{}
+	d := NewMultiServerDiscovery([]string{startServer(t, slow), startServer(t, fast)})
+	xc := NewXClient(d, P2CSelect, nil)
+	defer xc.Close()
+	for i := 0; i < 20; i++ {
+		var reply string
+		err := xc.Call(context.Background(), "Lookup.Get", "k", &reply)
+		testutil.Assert(err == nil && reply == "k", "call Lookup.Get error:%v reply:%s", err, reply)
+	}
+	calls := atomic.LoadInt32(&slow.calls)
+	testutil.Assert(calls <= 2, "expect calls to avoid the slow server, but it got %d of 20", calls)
+
+	t.Run("server update", func(t *testing.T) {
+		_ = d.Update([]string{startServer(t, &Lookup{})})
+		var reply string
+		err := xc.Call(context.Background(), "Lookup.Get", "k", &reply)
+		testutil.Assert(err == nil, "call Lookup.Get error:%v", err)
+		xc.statsMu.Lock()
+		n := len(xc.stats)
+		xc.statsMu.Unlock()
+		testutil.Assert(n == 1, "expect the stats of removed servers to be dropped, but got %d", n)
+	})
+	t.Run("failure penalty", func(t *testing.T) {
+		xc := NewXClient(NewMultiServerDiscovery([]string{"tcp@a", "tcp@b"}), P2CSelect, nil)
+		defer xc.Close()
+		xc.end("tcp@a", context.Background(), xc.begin("tcp@a"), errors.New("rpc server: bad argument"))
+		xc.end("tcp@b", context.Background(), xc.begin("tcp@b"), ErrShutdown)
+		testutil.Assert(xc.stats["tcp@a"].latency < float64(failurePenalty), "expect no penalty for an application error")
+		testutil.Assert(xc.stats["tcp@b"].latency >= float64(failurePenalty), "expect a penalty for a connection error")
+	})
+}
+
+func TestXClient_LeastOutstanding(t *testing.T) {
+	busy, idle := &Lookup{}, &Lookup{}
+	busyAddr := startServer(t, busy)
+	d := NewMultiServerDiscovery([]string{busyAddr, startServer(t, idle)})
+	xc := NewXClient(d, LeastOutstandingSelect, nil)
+	defer xc.Close()
+	call := func() {
+		var reply string
+		err := xc.Call(context.Background(), "Lookup.Get", "k", &reply)
+		testutil.Assert(err == nil && reply == "k", "call Lookup.Get error:%v reply:%s", err, reply)
+	}
+
+	start := xc.begin(busyAddr)
+	for i := 0; i < 10; i++ {
+		call()
+	}
+	calls := atomic.LoadInt32(&idle.calls)
+	testutil.Assert(calls == 10, "expect all calls on the idle server, but it got %d of 10", calls)
+
+	xc.end(busyAddr, context.Background(), start, nil)
+	for i := 0; i < 20; i++ {
+		call()
+	}
+	calls = atomic.LoadInt32(&busy.calls)
+	testutil.Assert(calls > 0, "expect calls on both servers once there is nothing in flight")
+}
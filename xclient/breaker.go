@@ -0,0 +1,252 @@
+package xclient
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"time"
+)
+
+// ErrBreakerOpen is returned when a call can't be sent because the circuit breaker is open.
+var ErrBreakerOpen = errors.New("rpc xclient: circuit breaker is open")
+
+// BreakerState is the state of the circuit breaker of a server.
+type BreakerState int
+
+const (
+	BreakerClosed   BreakerState = iota // calls go through
+	BreakerOpen                         // the server is removed from selection
+	BreakerHalfOpen                     // a trial call decides whether to close or open again
+)
+
+func (s BreakerState) String() string {
+	switch s {
+	case BreakerClosed:
+		return "closed"
+	case BreakerOpen:
+		return "open"
+	case BreakerHalfOpen:
+		return "half-open"
+	default:
+		return "unknown"
+	}
+}
+
+// BreakerPolicy configures the per-server circuit breakers of XClient.
+// A breaker trips once the ratio of failed calls in the window reaches FailureRatio,
+// stays open for CoolDown, then lets one trial call through.
+type BreakerPolicy struct {
+	FailureRatio float64              // ratio of failed calls that trips the breaker, in (0, 1], 0 means 0.5
+	MinRequests  int                  // calls in the window needed to trip the breaker, values below 1 mean 10
+	Window       time.Duration        // period after which the counts are reset, 0 means 10s
+	CoolDown     time.Duration        // how long a tripped breaker stays open, 0 means 5s
+	IsFailure    func(err error) bool // errors counted as failures, nil means all but cancelled calls
+}
+
+// BreakerMetrics is a snapshot of the circuit breaker of a server.
+type BreakerMetrics struct {
+	State    BreakerState
+	Requests int // calls in the current window
+	Failures int // failed calls in the current window
+	Trips    int // times the breaker has tripped
+	Rejected int // calls rejected while the breaker was open
+}
+
+// breaker is the circuit breaker of a server.
+type breaker struct {
+	metrics     BreakerMetrics
+	windowStart time.Time
+	openedAt    time.Time
+	trial       bool // a trial call is in flight while half-open
+}
+
+// breakers holds a BreakerPolicy and the breakers of the servers.
+type breakers struct {
+	policy BreakerPolicy
+	mu     sync.Mutex // protect following
+	m      map[string]*breaker
+}
+
+func newBreakers(policy BreakerPolicy) *breakers {
+	if policy.FailureRatio <= 0 || policy.FailureRatio > 1 {
+		policy.FailureRatio = 0.5
+	}
+	if policy.MinRequests < 1 {
+		policy.MinRequests = 10
+	}
+	if policy.Window <= 0 {
+		policy.Window = 10 * time.Second
+	}
+	if policy.CoolDown <= 0 {
+		policy.CoolDown = 5 * time.Second
+	}
+	return &breakers{policy: policy, m: make(map[string]*breaker)}
+}
+
+// get returns the breaker of rpcAddr with its state brought up to date, bs.mu must be held.
+func (bs *breakers) get(rpcAddr string) *breaker {
+	b, ok := bs.m[rpcAddr]
+	if !ok {
+		b = &breaker{windowStart: time.Now()}
+		bs.m[rpcAddr] = b
+	}
+	bs.refresh(b)
+	return b
+}
+
+// refresh brings the state of b up to date, bs.mu must be held.
+func (bs *breakers) refresh(b *breaker) {
+	now := time.Now()
+	switch b.metrics.State {
+	case BreakerClosed:
+		if now.Sub(b.windowStart) >= bs.policy.Window {
+			b.metrics.Requests, b.metrics.Failures = 0, 0
+			b.windowStart = now
+		}
+	case BreakerOpen:
+		if now.Sub(b.openedAt) >= bs.policy.CoolDown {
+			b.metrics.State = BreakerHalfOpen
+			b.trial = false
+		}
+	}
+}
+
+// prune drops the breakers of the servers not in servers, bs.mu must be held.
+func (bs *breakers) prune(servers []string) {
+	alive := make(map[string]bool, len(servers))
+	for _, server := range servers {
+		alive[server] = true
+	}
+	for rpcAddr := range bs.m {
+		if !alive[rpcAddr] {
+			delete(bs.m, rpcAddr)
+		}
+	}
+}
+
+// available reports whether rpcAddr may be selected.
+func (bs *breakers) available(rpcAddr string) bool {
+	if bs == nil {
+		return true
+	}
+	bs.mu.Lock()
+	defer bs.mu.Unlock()
+	b, ok := bs.m[rpcAddr]
+	if !ok {
+		return true
+	}
+	bs.refresh(b)
+	return b.metrics.State == BreakerClosed || b.metrics.State == BreakerHalfOpen && !b.trial
+}
+
+// acquire reports whether a call to rpcAddr may be sent, and whether it's the trial call of a half-open breaker.
+// The result of the call must be reported by done. The breakers of the servers no longer in servers,
+// such as the GetAll of the discovery, are dropped when a new server is called.
+func (bs *breakers) acquire(rpcAddr string, servers func() ([]string, error)) (ok, trial bool) {
+	if bs == nil {
+		return true, false
+	}
+	bs.mu.Lock()
+	_, known := bs.m[rpcAddr]
+	bs.mu.Unlock()
+	var alive []string
+	fetched := false
+	if !known {
+		// servers is called without the lock since it may ask a registry
+		var err error
+		alive, err = servers()
+		fetched = err == nil
+	}
+	bs.mu.Lock()
+	defer bs.mu.Unlock()
+	if _, known := bs.m[rpcAddr]; !known && fetched {
+		bs.prune(alive)
+	}
+	b := bs.get(rpcAddr)
+	switch {
+	case b.metrics.State == BreakerClosed:
+	case b.metrics.State == BreakerHalfOpen && !b.trial:
+		b.trial = true
+		return true, true
+	default:
+		b.metrics.Rejected++
+		return false, false
+	}
+	return true, false
+}
+
+// done records the result of a call to rpcAddr made with ctx, trial is as returned by acquire.
+func (bs *breakers) done(rpcAddr string, ctx context.Context, trial bool, err error) {
+	if bs == nil {
+		return
+	}
+	bs.mu.Lock()
+	defer bs.mu.Unlock()
+	b := bs.get(rpcAddr)
+	// calls cancelled by the caller say nothing about the server
+	failed := err != nil && !errors.Is(ctx.Err(), context.Canceled) &&
+		(bs.policy.IsFailure == nil || bs.policy.IsFailure(err))
+	switch b.metrics.State {
+	case BreakerHalfOpen:
+		// calls sent before the breaker tripped say nothing about the server now
+		if !trial {
+			return
+		}
+		b.trial = false
+		switch {
+		case failed:
+			bs.trip(b)
+		case err == nil:
+			b.metrics = BreakerMetrics{State: BreakerClosed, Trips: b.metrics.Trips, Rejected: b.metrics.Rejected}
+			b.windowStart = time.Now()
+		}
+	case BreakerClosed:
+		if err != nil && !failed {
+			return
+		}
+		b.metrics.Requests++
+		if failed {
+			b.metrics.Failures++
+		}
+		if b.metrics.Requests >= bs.policy.MinRequests &&
+			float64(b.metrics.Failures) >= bs.policy.FailureRatio*float64(b.metrics.Requests) {
+			bs.trip(b)
+		}
+	}
+}
+
+func (bs *breakers) trip(b *breaker) {
+	b.metrics.State = BreakerOpen
+	b.metrics.Trips++
+	b.openedAt = time.Now()
+}
+
+// SetBreakerPolicy enables a circuit breaker per server address, nil disables them.
+// Servers with an open breaker are removed from selection until the cool-down passes.
+func (xc *XClient) SetBreakerPolicy(policy *BreakerPolicy) {
+	xc.mu.Lock()
+	defer xc.mu.Unlock()
+	if policy == nil {
+		xc.breakers = nil
+		return
+	}
+	xc.breakers = newBreakers(*policy)
+}
+
+// BreakerMetrics returns the metrics of the circuit breakers keyed by server address,
+// nil if there is no breaker policy.
+func (xc *XClient) BreakerMetrics() map[string]BreakerMetrics {
+	xc.mu.Lock()
+	bs := xc.breakers
+	xc.mu.Unlock()
+	if bs == nil {
+		return nil
+	}
+	bs.mu.Lock()
+	defer bs.mu.Unlock()
+	metrics := make(map[string]BreakerMetrics, len(bs.m))
+	for rpcAddr := range bs.m {
+		metrics[rpcAddr] = bs.get(rpcAddr).metrics
+	}
+	return metrics
+}
@@ -0,0 +1,90 @@
+package xclient
+
+import (
+	"context"
+	"github.com/GallifreyGoTutoural/ggt-rpc/internal/testutil"
+	. "github.com/GallifreyGoTutoural/ggt-rpc/rpc"
+	"testing"
+	"time"
+)
+
+func TestXClient_Breaker(t *testing.T) {
+	dead := deadServer(t)
+	d := NewMultiServerDiscovery([]string{dead, startServer(t)})
+	xc := NewXClient(d, RoundRobinSelect, nil)
+	defer xc.Close()
+	xc.SetBreakerPolicy(&BreakerPolicy{MinRequests: 2, CoolDown: 200 * time.Millisecond})
+
+	call := func() error {
+		var reply int
+		return xc.Call(context.Background(), "Foo.Sum", &Args{Num1: 1, Num2: 2}, &reply)
+	}
+	failed := 0
+	for i := 0; i < 10; i++ {
+		if call() != nil {
+			failed++
+		}
+	}
+	testutil.Assert(failed == 2, "expect the breaker to trip after 2 failures, but %d calls failed", failed)
+	m := xc.BreakerMetrics()[dead]
+	testutil.Assert(m.State == BreakerOpen && m.Trips == 1, "unexpected metrics of the dead server: %+v", m)
+
+	t.Run("half-open", func(t *testing.T) {
+		time.Sleep(250 * time.Millisecond)
+		m := xc.BreakerMetrics()[dead]
+		testutil.Assert(m.State == BreakerHalfOpen, "expect half-open after the cool-down, but got %s", m.State)
+		failed := 0
+		for i := 0; i < 4; i++ {
+			if call() != nil {
+				failed++
+			}
+		}
+		m = xc.BreakerMetrics()[dead]
+		testutil.Assert(failed == 1 && m.State == BreakerOpen && m.Trips == 2, "expect the trial call to trip the breaker again, failed:%d metrics:%+v", failed, m)
+	})
+	t.Run("broadcast", func(t *testing.T) {
+		xc := NewXClient(d, RandomSelect, nil)
+		defer xc.Close()
+		xc.SetBreakerPolicy(&BreakerPolicy{MinRequests: 1, CoolDown: time.Minute})
+		var reply int
+		err := xc.Broadcast(context.Background(), "Foo.Sum", &Args{Num1: 1, Num2: 2}, &reply)
+		testutil.Assert(err != nil, "expect the dead server to fail the broadcast")
+		err = xc.Broadcast(context.Background(), "Foo.Sum", &Args{Num1: 1, Num2: 2}, &reply)
+		testutil.Assert(err == nil && reply == 3, "expect the open breaker to be skipped, error:%v reply:%d", err, reply)
+		results, _ := xc.BroadcastAll(context.Background(), "Foo.Sum", &Args{Num1: 1, Num2: 2}, &reply)
+		testutil.Assert(results[dead].Error == ErrBreakerOpen, "expect ErrBreakerOpen for %s, but got %v", dead, results[dead].Error)
+	})
+	t.Run("all open", func(t *testing.T) {
+		xc := NewXClient(NewMultiServerDiscovery([]string{dead}), RandomSelect, nil)
+		defer xc.Close()
+		xc.SetBreakerPolicy(&BreakerPolicy{MinRequests: 1, CoolDown: time.Minute})
+		_ = xc.Call(context.Background(), "Foo.Sum", &Args{}, nil)
+		err := xc.Call(context.Background(), "Foo.Sum", &Args{}, nil)
+		testutil.Assert(err == ErrBreakerOpen, "expect ErrBreakerOpen, but got %v", err)
+	})
+}
+
+func TestBreakers(t *testing.T) {
+	servers := func() ([]string, error) { return []string{"tcp@a"}, nil }
+	bs := newBreakers(BreakerPolicy{FailureRatio: 1, MinRequests: 1, CoolDown: 10 * time.Millisecond})
+	ctx := context.Background()
+
+	t.Run("stale calls", func(t *testing.T) {
+		_, staleTrial := bs.acquire("tcp@a", servers)
+		_, trial := bs.acquire("tcp@a", servers)
+		bs.done("tcp@a", ctx, trial, ErrShutdown)
+		testutil.Assert(!staleTrial && !bs.available("tcp@a"), "expect the breaker to trip")
+		time.Sleep(20 * time.Millisecond)
+		ok, trial := bs.acquire("tcp@a", servers)
+		testutil.Assert(ok && trial, "expect a trial call once half-open")
+		bs.done("tcp@a", ctx, staleTrial, nil)
+		testutil.Assert(bs.m["tcp@a"].metrics.State == BreakerHalfOpen, "expect a call sent before the trip to be ignored")
+		bs.done("tcp@a", ctx, trial, nil)
+		testutil.Assert(bs.m["tcp@a"].metrics.State == BreakerClosed, "expect the trial call to close the breaker")
+	})
+	t.Run("servers removed", func(t *testing.T) {
+		bs.acquire("tcp@b", func() ([]string, error) { return []string{"tcp@b"}, nil })
+		_, ok := bs.m["tcp@a"]
+		testutil.Assert(!ok && len(bs.m) == 1, "expect the breaker of tcp@a to be dropped: %v", bs.m)
+	})
+}
@@ -0,0 +1,104 @@
+package xclient
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"reflect"
+	"sync"
+)
+
+// ErrNoQuorum is returned by Quorum when not enough servers agree.
+var ErrNoQuorum = errors.New("rpc xclient: no quorum")
+
+// BroadcastResult is the result of the call to one server in BroadcastAll.
+type BroadcastResult struct {
+	Reply interface{} // pointer to a new value of the type reply points to, nil if reply is nil
+	Error error
+}
+
+// BroadcastAll invokes the named function on every server, waits for all of them,
+// and returns the result of each keyed by server address. reply only gives the type of the replies.
+// Servers whose breaker is open have ErrBreakerOpen as result.
+func (xc *XClient) BroadcastAll(ctx context.Context, serviceMethod string, args, reply interface{}) (map[string]BroadcastResult, error) {
+	servers, err := xc.serversFor(ctx, serviceMethod)
+	if err != nil {
+		return nil, err
+	}
+	var wg sync.WaitGroup
+	var mu sync.Mutex
+	results := make(map[string]BroadcastResult, len(servers))
+	for _, rpcAddr := range servers {
+		wg.Add(1)
+		go func(rpcAddr string) {
+			defer wg.Done()
+			clone := cloneReply(reply)
+			err := xc.call(rpcAddr, ctx, serviceMethod, args, clone)
+			mu.Lock()
+			results[rpcAddr] = BroadcastResult{Reply: clone, Error: err}
+			mu.Unlock()
+		}(rpcAddr)
+	}
+	wg.Wait()
+	return results, nil
+}
+
+// Quorum invokes the named function on every server, and sets reply once n servers reply with equal values.
+// It fails with ErrNoQuorum once that's no longer possible.
+func (xc *XClient) Quorum(ctx context.Context, n int, serviceMethod string, args, reply interface{}) error {
+	servers, err := xc.serversFor(ctx, serviceMethod)
+	if err != nil {
+		return err
+	}
+	if n < 1 || n > len(servers) {
+		return fmt.Errorf("%w: need %d of %d servers", ErrNoQuorum, n, len(servers))
+	}
+	type result struct {
+		clone interface{}
+		err   error
+	}
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	results := make(chan result, len(servers))
+	for _, rpcAddr := range servers {
+		go func(rpcAddr string) {
+			clone := cloneReply(reply)
+			err := xc.call(rpcAddr, ctx, serviceMethod, args, clone)
+			results <- result{clone: clone, err: err}
+		}(rpcAddr)
+	}
+
+	var replies []interface{} // distinct replies so far
+	var counts []int          // servers agreeing on each of replies
+	var lastErr error
+	for pending := len(servers); pending > 0; pending-- {
+		r := <-results
+		if r.err != nil {
+			lastErr = r.err
+		} else {
+			i := 0
+			for i < len(replies) && !reflect.DeepEqual(replies[i], r.clone) {
+				i++
+			}
+			if i == len(replies) {
+				replies = append(replies, r.clone)
+				counts = append(counts, 0)
+			}
+			counts[i]++
+			if counts[i] >= n {
+				setReply(reply, replies[i])
+				return nil
+			}
+		}
+		best := 0
+		for _, c := range counts {
+			if c > best {
+				best = c
+			}
+		}
+		if best+pending-1 < n {
+			break
+		}
+	}
+	return fmt.Errorf("%w: need %d of %d servers, last error: %v", ErrNoQuorum, n, len(servers), lastErr)
+}
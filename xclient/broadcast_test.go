@@ -0,0 +1,52 @@
+package xclient
+
+import (
+	"context"
+	"errors"
+	"github.com/GallifreyGoTutoural/ggt-rpc/internal/testutil"
+	. "github.com/GallifreyGoTutoural/ggt-rpc/rpc"
+	"testing"
+)
+
+// Replica replies its value to every read.
+type Replica struct{ value string }
+
+func (r *Replica) Read(key string, reply *string) error {
+	*reply = r.value
+	return nil
+}
+
+func TestXClient_BroadcastAll(t *testing.T) {
+	dead := deadServer(t)
+	ok1, ok2 := startServer(t), startServer(t)
+	xc := NewXClient(NewMultiServerDiscovery([]string{ok1, ok2, dead}), RandomSelect, nil)
+	defer xc.Close()
+	var reply int
+	results, err := xc.BroadcastAll(context.Background(), "Foo.Sum", &Args{Num1: 1, Num2: 2}, &reply)
+	testutil.Assert(err == nil && len(results) == 3, "BroadcastAll() error:%v results:%v", err, results)
+	for _, addr := range []string{ok1, ok2} {
+		r := results[addr]
+		testutil.Assert(r.Error == nil && *r.Reply.(*int) == 3, "unexpected result of %s: %+v", addr, r)
+	}
+	testutil.Assert(results[dead].Error != nil, "expect an error from the dead server")
+	testutil.Assert(reply == 0, "expect reply untouched, but got %d", reply)
+}
+
+func TestXClient_Quorum(t *testing.T) {
+	servers := []string{
+		startServer(t, &Replica{value: "v2"}),
+		startServer(t, &Replica{value: "v1"}),
+		startServer(t, &Replica{value: "v2"}),
+		deadServer(t),
+	}
+	xc := NewXClient(NewMultiServerDiscovery(servers), RandomSelect, nil)
+	defer xc.Close()
+
+	var reply string
+	err := xc.Quorum(context.Background(), 2, "Replica.Read", "k", &reply)
+	testutil.Assert(err == nil && reply == "v2", "Quorum(2) error:%v reply:%s", err, reply)
+	err = xc.Quorum(context.Background(), 3, "Replica.Read", "k", &reply)
+	testutil.Assert(errors.Is(err, ErrNoQuorum), "expect ErrNoQuorum, but got %v", err)
+	err = xc.Quorum(context.Background(), 5, "Replica.Read", "k", &reply)
+	testutil.Assert(errors.Is(err, ErrNoQuorum), "expect ErrNoQuorum, but got %v", err)
+}
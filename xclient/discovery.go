@@ -4,6 +4,7 @@ import (
 	"errors"
 	"math"
 	"math/rand"
+	"strconv"
 	"sync"
 	"time"
 )
@@ -13,8 +14,15 @@ type SelectMode int
 const (
 	RandomSelect SelectMode = iota
 	RoundRobinSelect
+	WeightedRoundRobinSelect // smooth weighted round-robin, weights come from the "weight" metadata
+	ConsistentHashSelect     // rendezvous hashing on the key of the call, selected by XClient
+	P2CSelect                // power of two choices on EWMA latency and calls in flight, selected by XClient
+	LeastOutstandingSelect   // fewest calls in flight, selected by XClient
 )
 
+// MetadataWeight is the metadata key of the weight of a server, values below 1 mean 1.
+const MetadataWeight = "weight"
+
 type Discovery interface {
 	Refresh() error
 	Update(servers []string) error
@@ -26,21 +34,41 @@ type MultiServersDiscovery struct {
 	r       *rand.Rand
 	servers []string
 	index   int
+	meta    map[string]map[string]string // metadata of the servers
+	current map[string]int               // current weights of smooth weighted round-robin
 	mu      sync.Mutex
 }
 
-func (m MultiServersDiscovery) Refresh() error {
+func (m *MultiServersDiscovery) Refresh() error {
 	return nil
 }
 
-func (m MultiServersDiscovery) Update(servers []string) error {
+func (m *MultiServersDiscovery) Update(servers []string) error {
 	m.mu.Lock()
 	defer m.mu.Unlock()
-	m.servers = servers
+	m.setServers(servers)
 	return nil
 }
 
-func (m MultiServersDiscovery) Get(mode SelectMode) (string, error) {
+// setServers replaces the servers, m.mu must be held.
+// The current weights of removed servers are dropped, so that a server added again starts afresh.
+func (m *MultiServersDiscovery) setServers(servers []string) {
+	m.servers = servers
+	if len(m.current) == 0 {
+		return
+	}
+	alive := make(map[string]bool, len(servers))
+	for _, server := range servers {
+		alive[server] = true
+	}
+	for server := range m.current {
+		if !alive[server] {
+			delete(m.current, server)
+		}
+	}
+}
+
+func (m *MultiServersDiscovery) Get(mode SelectMode) (string, error) {
 	m.mu.Lock()
 	defer m.mu.Unlock()
 	n := len(m.servers)
@@ -54,12 +82,79 @@ func (m MultiServersDiscovery) Get(mode SelectMode) (string, error) {
 		s := m.servers[m.index%n]
 		m.index = (m.index + 1) % n
 		return s, nil
+	case WeightedRoundRobinSelect:
+		return m.nextWeighted(), nil
 	default:
 		return "", errors.New("rpc discovery: not supported select mode")
 	}
 }
 
-func (m MultiServersDiscovery) GetAll() ([]string, error) {
+// nextWeighted selects a server with smooth weighted round-robin, m.mu must be held.
+// Every server gains its weight, the one with the largest current weight is selected and loses the total weight,
+// which spreads the selections of heavy servers evenly.
+func (m *MultiServersDiscovery) nextWeighted() string {
+	if m.current == nil {
+		m.current = make(map[string]int)
+	}
+	var best string
+	total := 0
+	for _, server := range m.servers {
+		weight := m.weightOf(server)
+		total += weight
+		m.current[server] += weight
+		if best == "" || m.current[server] > m.current[best] {
+			best = server
+		}
+	}
+	m.current[best] -= total
+	return best
+}
+
+// weightOf returns the weight of server, m.mu must be held.
+func (m *MultiServersDiscovery) weightOf(server string) int {
+	weight, err := strconv.Atoi(m.meta[server][MetadataWeight])
+	if err != nil || weight < 1 {
+		return 1
+	}
+	return weight
+}
+
+// UpdateMetadata replaces the metadata of the server, which takes effect on the next selection.
+func (m *MultiServersDiscovery) UpdateMetadata(rpcAddr string, meta map[string]string) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if m.meta == nil {
+		m.meta = make(map[string]map[string]string)
+	}
+	m.meta[rpcAddr] = meta
+	// the weights may have changed, start the smooth weighted round-robin afresh
+	m.current = nil
+}
+
+// SetWeight sets the weight of the server for WeightedRoundRobinSelect.
+func (m *MultiServersDiscovery) SetWeight(rpcAddr string, weight int) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if m.meta == nil {
+		m.meta = make(map[string]map[string]string)
+	}
+	meta := make(map[string]string, len(m.meta[rpcAddr])+1)
+	for k, v := range m.meta[rpcAddr] {
+		meta[k] = v
+	}
+	meta[MetadataWeight] = strconv.Itoa(weight)
+	m.meta[rpcAddr] = meta
+	m.current = nil
+}
+
+// Metadata returns the metadata of the server, nil if it has none. The map must not be modified.
+func (m *MultiServersDiscovery) Metadata(rpcAddr string) map[string]string {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return m.meta[rpcAddr]
+}
+
+func (m *MultiServersDiscovery) GetAll() ([]string, error) {
 	m.mu.Lock()
 	defer m.mu.Unlock()
 	servers := make([]string, len(m.servers), len(m.servers))
@@ -3,6 +3,7 @@ package xclient
 import (
 	"log"
 	"net/http"
+	"net/url"
 	"strings"
 	"time"
 )
@@ -10,6 +11,7 @@ import (
 type GGTRegistryDiscovery struct {
 	*MultiServersDiscovery
 	registry   string
+	query      string // the service and version constraint to filter the servers with, if any
 	timeout    time.Duration
 	lastUpdate time.Time
 }
@@ -28,10 +30,18 @@ func NewGGTRegistryDiscovery(registerAddr string, timeout time.Duration) *GGTReg
 	return d
 }
 
+// NewGGTRegistryDiscoveryForVersion is like NewGGTRegistryDiscovery, and only discovers the servers
+// advertising a version of service matching constraint, see version.Matches.
+func NewGGTRegistryDiscoveryForVersion(registerAddr, service, constraint string, timeout time.Duration) *GGTRegistryDiscovery {
+	d := NewGGTRegistryDiscovery(registerAddr, timeout)
+	d.query = url.Values{"service": {service}, "version": {constraint}}.Encode()
+	return d
+}
+
 func (d *GGTRegistryDiscovery) Update(servers []string) error {
 	d.mu.Lock()
 	defer d.mu.Unlock()
-	d.servers = servers
+	d.setServers(servers)
 	d.lastUpdate = time.Now()
 	return nil
 }
@@ -43,17 +53,36 @@ func (d *GGTRegistryDiscovery) Refresh() error {
 		return nil
 	}
 	log.Printf("rpc registry: refresh servers from registry %s", d.registry)
-	resp, err := http.Get(d.registry)
+	addr := d.registry
+	if d.query != "" {
+		addr += "?" + d.query
+	}
+	resp, err := http.Get(addr)
 	if err != nil {
 		log.Println("rpc registry refresh err:", err)
 		return err
 	}
-	servers := strings.Split(resp.Header.Get("X-GGT-RPC-SERVER-LIST"), ",")
-	d.servers = make([]string, 0, len(servers))
-	for _, server := range servers {
+	list := strings.Split(resp.Header.Get("X-GGT-RPC-SERVER-LIST"), ",")
+	servers := make([]string, 0, len(list))
+	for _, server := range list {
 		if strings.TrimSpace(server) != "" {
-			d.servers = append(d.servers, strings.TrimSpace(server))
+			servers = append(servers, strings.TrimSpace(server))
+		}
+	}
+	d.setServers(servers)
+	d.meta = make(map[string]map[string]string)
+	for _, value := range resp.Header.Values("X-GGT-RPC-SERVER-META") {
+		addr, encoded, _ := strings.Cut(value, " ")
+		values, err := url.ParseQuery(encoded)
+		if err != nil {
+			log.Printf("rpc registry: invalid metadata of %s: %v", addr, err)
+			continue
+		}
+		meta := make(map[string]string, len(values))
+		for k := range values {
+			meta[k] = values.Get(k)
 		}
+		d.meta[addr] = meta
 	}
 	d.lastUpdate = time.Now()
 	return nil
@@ -0,0 +1,98 @@
+package xclient
+
+import (
+	"github.com/GallifreyGoTutoural/ggt-rpc/internal/testutil"
+	"github.com/GallifreyGoTutoural/ggt-rpc/registry"
+	. "github.com/GallifreyGoTutoural/ggt-rpc/rpc"
+	"github.com/GallifreyGoTutoural/ggt-rpc/version"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestMultiServersDiscovery_WeightedRoundRobin(t *testing.T) {
+	d := NewMultiServerDiscovery([]string{"a", "b", "c"})
+	d.SetWeight("a", 5)
+	counts := make(map[string]int)
+	var picks string
+	for i := 0; i < 7; i++ {
+		s, err := d.Get(WeightedRoundRobinSelect)
+		testutil.Assert(err == nil, "Get() error:%v", err)
+		counts[s]++
+		picks += s
+	}
+	testutil.Assert(counts["a"] == 5 && counts["b"] == 1 && counts["c"] == 1, "unexpected selections: %v", counts)
+	testutil.Assert(picks == "aabacaa", "expect smooth selections, but got %s", picks)
+
+	t.Run("weight update", func(t *testing.T) {
+		d.SetWeight("a", 1)
+		counts := make(map[string]int)
+		for i := 0; i < 30; i++ {
+			s, _ := d.Get(WeightedRoundRobinSelect)
+			counts[s]++
+		}
+		testutil.Assert(counts["a"] == 10 && counts["b"] == 10 && counts["c"] == 10, "unexpected selections: %v", counts)
+	})
+	t.Run("server update", func(t *testing.T) {
+		d := NewMultiServerDiscovery([]string{"a", "b"})
+		d.SetWeight("a", 3)
+		_, _ = d.Get(WeightedRoundRobinSelect)
+		_ = d.Update([]string{"b"})
+		testutil.Assert(len(d.current) == 1, "expect the current weight of a to be dropped: %v", d.current)
+		_ = d.Update([]string{"a", "b"})
+		var picks string
+		for i := 0; i < 4; i++ {
+			s, _ := d.Get(WeightedRoundRobinSelect)
+			picks += s
+		}
+		testutil.Assert(picks == "aaba" || picks == "abaa", "expect a to start afresh, but got %s", picks)
+	})
+}
+
+func TestGGTRegistryDiscovery_Metadata(t *testing.T) {
+	ts := httptest.NewServer(registry.New(time.Minute))
+	defer ts.Close()
+	registry.HeartbeatWithMetadata(ts.URL, "tcp@a", time.Hour, map[string]string{MetadataWeight: "3"})
+	registry.Heartbeat(ts.URL, "tcp@b", time.Hour)
+
+	d := NewGGTRegistryDiscovery(ts.URL, 0)
+	servers, err := d.GetAll()
+	testutil.Assert(err == nil && len(servers) == 2, "GetAll() error:%v servers:%v", err, servers)
+	testutil.Assert(d.Metadata("tcp@a")[MetadataWeight] == "3", "unexpected metadata: %v", d.Metadata("tcp@a"))
+	testutil.Assert(d.Metadata("tcp@b") == nil, "unexpected metadata: %v", d.Metadata("tcp@b"))
+}
+
+func TestGGTRegistryDiscovery_Version(t *testing.T) {
+	ts := httptest.NewServer(registry.New(time.Minute))
+	defer ts.Close()
+	registry.HeartbeatWithMetadata(ts.URL, "tcp@a", time.Hour, map[string]string{version.Key("Foo"): "1.2"})
+	registry.HeartbeatWithMetadata(ts.URL, "tcp@b", time.Hour, map[string]string{version.Key("Foo"): "1.2 2.0"})
+	registry.Heartbeat(ts.URL, "tcp@c", time.Hour)
+
+	for constraint, want := range map[string]int{"": 3, "1.x": 3, ">=2": 2, "3": 1} {
+		d := NewGGTRegistryDiscoveryForVersion(ts.URL, "Foo", constraint, 0)
+		servers, err := d.GetAll()
+		testutil.Assert(err == nil && len(servers) == want, "constraint %q: GetAll() error:%v servers:%v", constraint, err, servers)
+	}
+}
+
+func TestGGTRegistryDiscovery_HealthServing(t *testing.T) {
+	ts := httptest.NewServer(registry.New(time.Minute))
+	defer ts.Close()
+	health, _ := NewServer().RegisterHealth()
+	registry.HeartbeatWhileServing(ts.URL, "tcp@a", 20*time.Millisecond, nil, health.Serving)
+	d := NewGGTRegistryDiscovery(ts.URL, time.Nanosecond)
+	servers := func() []string {
+		servers, err := d.GetAll()
+		testutil.Assert(err == nil, "GetAll() error:%v", err)
+		return servers
+	}
+	testutil.Assert(len(servers()) == 1, "expect the serving server to be registered")
+
+	health.Shutdown()
+	time.Sleep(100 * time.Millisecond)
+	testutil.Assert(len(servers()) == 0, "expect the server to be removed once it stops serving, but got %v", servers())
+	health.Resume()
+	time.Sleep(100 * time.Millisecond)
+	testutil.Assert(len(servers()) == 1, "expect the server to be registered again once it serves")
+}
@@ -0,0 +1,121 @@
+package xclient
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	. "github.com/GallifreyGoTutoural/ggt-rpc/rpc"
+	"net"
+)
+
+// FailMode decides what Call does when a call fails with a connection error, 0 leaves it to the retry and hedge policies.
+// Calls that may have reached the server are only sent again to idempotent methods.
+type FailMode int
+
+const (
+	Failfast FailMode = iota + 1 // make one attempt
+	Failover                     // try another server, each server at most once
+	Failtry                      // retry the same server with the attempts and backoff of the retry policy
+)
+
+// failtryAttempts is the number of attempts of Failtry without a retry policy.
+const failtryAttempts = 3
+
+func (m FailMode) String() string {
+	switch m {
+	case Failfast:
+		return "failfast"
+	case Failover:
+		return "failover"
+	case Failtry:
+		return "failtry"
+	default:
+		return fmt.Sprintf("FailMode(%d)", int(m))
+	}
+}
+
+type failModeCtxKey struct{}
+
+// WithFailMode returns a copy of ctx overriding the fail mode of the XClient for the call.
+func WithFailMode(ctx context.Context, mode FailMode) context.Context {
+	return context.WithValue(ctx, failModeCtxKey{}, mode)
+}
+
+// SetFailMode sets the fail mode of Call, which replaces the retry and hedge policies unless it's 0.
+func (xc *XClient) SetFailMode(mode FailMode) {
+	xc.mu.Lock()
+	defer xc.mu.Unlock()
+	xc.failMode = mode
+}
+
+// failModeOf returns the fail mode of the call made with ctx.
+func (xc *XClient) failModeOf(ctx context.Context) FailMode {
+	if mode, ok := ctx.Value(failModeCtxKey{}).(FailMode); ok {
+		return mode
+	}
+	xc.mu.Lock()
+	defer xc.mu.Unlock()
+	return xc.failMode
+}
+
+// callWithFailMode calls serviceMethod, handling connection-level errors as mode says.
+func (xc *XClient) callWithFailMode(mode FailMode, ctx context.Context, serviceMethod string, args, reply interface{}) error {
+	xc.mu.Lock()
+	policy := xc.retry.policyOf(serviceMethod)
+	maxAttempts, backoff := failtryAttempts, DefaultBackoff
+	if xc.retry != nil {
+		maxAttempts, backoff = xc.retry.MaxAttempts, xc.retry.Backoff
+	}
+	if policy != nil {
+		maxAttempts, backoff = policy.MaxAttempts, policy.Backoff
+	}
+	xc.mu.Unlock()
+
+	tried := make(map[string]bool)
+	rpcAddr, err := xc.selectServer(ctx, serviceMethod, args, tried)
+	if err != nil {
+		return err
+	}
+	for attempt := 0; ; attempt++ {
+		tried[rpcAddr] = true
+		err = xc.call(rpcAddr, ctx, serviceMethod, args, reply)
+		if err == nil || !isConnError(err) || ctx.Err() != nil || policy == nil && !unsent(err) {
+			return err
+		}
+		switch mode {
+		case Failover:
+			next, ok := xc.nextServer(ctx, serviceMethod, tried)
+			if !ok {
+				return err
+			}
+			rpcAddr = next
+		case Failtry:
+			if attempt+1 >= maxAttempts || !sleep(ctx, backoff.Delay(attempt)) {
+				return err
+			}
+		default:
+			return err
+		}
+	}
+}
+
+// unsent reports whether the call failed before reaching the server.
+func unsent(err error) bool {
+	var opErr *net.OpError
+	return errors.Is(err, ErrBreakerOpen) || errors.Is(err, ErrShutdown) || errors.Is(err, ErrConnectTimeout) ||
+		errors.As(err, &opErr) && opErr.Op == "dial"
+}
+
+// nextServer returns the first compatible server not tried yet whose breaker isn't open.
+func (xc *XClient) nextServer(ctx context.Context, serviceMethod string, tried map[string]bool) (string, bool) {
+	servers, err := xc.candidates(tried, xc.compatible(ctx, serviceMethod))
+	if err != nil {
+		return "", false
+	}
+	for _, addr := range servers {
+		if !tried[addr] {
+			return addr, true
+		}
+	}
+	return "", false
+}
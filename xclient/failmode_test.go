@@ -0,0 +1,86 @@
+package xclient
+
+import (
+	"bufio"
+	"context"
+	"github.com/GallifreyGoTutoural/ggt-rpc/internal/testutil"
+	. "github.com/GallifreyGoTutoural/ggt-rpc/rpc"
+	"net"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// dropServer returns the address of a server that closes each connection once a request arrives,
+// and counts the requests.
+func dropServer(t *testing.T, requests *int32) string {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { _ = l.Close() })
+	go func() {
+		for {
+			conn, err := l.Accept()
+			if err != nil {
+				return
+			}
+			go func() {
+				defer conn.Close()
+				br := bufio.NewReader(conn)
+				if _, err := br.ReadString('\n'); err != nil {
+					return
+				}
+				if _, err := br.ReadByte(); err == nil {
+					atomic.AddInt32(requests, 1)
+				}
+			}()
+		}
+	}()
+	return "tcp@" + l.Addr().String()
+}
+
+func TestXClient_FailMode(t *testing.T) {
+	d := NewMultiServerDiscovery([]string{deadServer(t), startServer(t)})
+	xc := NewXClient(d, RoundRobinSelect, nil)
+	defer xc.Close()
+	xc.SetFailMode(Failover)
+	failures := func(ctx context.Context) int {
+		failed := 0
+		for i := 0; i < 4; i++ {
+			var reply int
+			if err := xc.Call(ctx, "Foo.Sum", &Args{Num1: 1, Num2: 2}, &reply); err != nil {
+				failed++
+			} else {
+				testutil.Assert(reply == 3, "expect reply 3, but got %d", reply)
+			}
+		}
+		return failed
+	}
+
+	t.Run("failover", func(t *testing.T) {
+		failed := failures(context.Background())
+		testutil.Assert(failed == 0, "expect calls to fail over to the live server, but %d failed", failed)
+	})
+	t.Run("failfast per call", func(t *testing.T) {
+		failed := failures(WithFailMode(context.Background(), Failfast))
+		testutil.Assert(failed == 2, "expect 2 of 4 calls to fail, but %d failed", failed)
+	})
+	t.Run("failtry per call", func(t *testing.T) {
+		failed := failures(WithFailMode(context.Background(), Failtry))
+		testutil.Assert(failed == 2, "expect calls to stay on the same server, but %d of 4 failed", failed)
+	})
+	t.Run("sent calls", func(t *testing.T) {
+		var requests int32
+		xc := NewXClient(NewMultiServerDiscovery([]string{dropServer(t, &requests)}), RandomSelect, nil)
+		defer xc.Close()
+		xc.SetFailMode(Failtry)
+		var reply int
+		err := xc.Call(context.Background(), "Foo.Sum", &Args{Num1: 1, Num2: 2}, &reply)
+		testutil.Assert(err != nil && atomic.LoadInt32(&requests) == 1, "expect no retry of a sent call, error:%v requests:%d", err, atomic.LoadInt32(&requests))
+
+		xc.SetRetryPolicy(&RetryPolicy{MaxAttempts: 2, Backoff: Backoff{Base: time.Millisecond}, Idempotent: []string{"Foo.Sum"}})
+		err = xc.Call(context.Background(), "Foo.Sum", &Args{Num1: 1, Num2: 2}, &reply)
+		testutil.Assert(err != nil && atomic.LoadInt32(&requests) == 3, "expect an idempotent call to be retried once, error:%v requests:%d", err, atomic.LoadInt32(&requests))
+	})
+}
@@ -0,0 +1,71 @@
+package xclient
+
+import (
+	"context"
+	"hash/fnv"
+	"math/rand"
+)
+
+type hashKeyCtxKey struct{}
+
+// WithHashKey returns a copy of ctx carrying the key of ConsistentHashSelect,
+// calls with the same key go to the same server as long as it's available.
+// It takes precedence over the HashKeyFunc of the XClient.
+func WithHashKey(ctx context.Context, key string) context.Context {
+	return context.WithValue(ctx, hashKeyCtxKey{}, key)
+}
+
+// HashKeyFunc extracts the key of ConsistentHashSelect from the arguments of a call.
+type HashKeyFunc func(serviceMethod string, args interface{}) string
+
+// SetHashKeyFunc sets the function extracting the key of ConsistentHashSelect from calls without WithHashKey.
+func (xc *XClient) SetHashKeyFunc(fn HashKeyFunc) {
+	xc.mu.Lock()
+	defer xc.mu.Unlock()
+	xc.hashKeyFunc = fn
+}
+
+// hashKey returns the key of the call, "" if it has none.
+func (xc *XClient) hashKey(ctx context.Context, serviceMethod string, args interface{}) string {
+	if key, ok := ctx.Value(hashKeyCtxKey{}).(string); ok {
+		return key
+	}
+	xc.mu.Lock()
+	fn := xc.hashKeyFunc
+	xc.mu.Unlock()
+	if fn == nil {
+		return ""
+	}
+	return fn(serviceMethod, args)
+}
+
+// hashServer selects the server with the highest score for key by rendezvous hashing,
+// so that adding or removing a server only moves the keys of that server. Calls without a key go to a random server.
+func (xc *XClient) hashServer(servers []string, key string) string {
+	if key == "" {
+		return servers[rand.Intn(len(servers))]
+	}
+	var best string
+	var bestScore uint64
+	for _, server := range servers {
+		if score := rendezvousScore(server, key); best == "" || score > bestScore {
+			best, bestScore = server, score
+		}
+	}
+	return best
+}
+
+func rendezvousScore(server, key string) uint64 {
+	h := fnv.New64a()
+	_, _ = h.Write([]byte(server))
+	_, _ = h.Write([]byte{0})
+	_, _ = h.Write([]byte(key))
+	// mix the bits, so that similar addresses get unrelated scores
+	x := h.Sum64()
+	x ^= x >> 30
+	x *= 0xbf58476d1ce4e5b9
+	x ^= x >> 27
+	x *= 0x94d049bb133111eb
+	x ^= x >> 31
+	return x
+}
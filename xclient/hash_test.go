@@ -0,0 +1,58 @@
+package xclient
+
+import (
+	"context"
+	"fmt"
+	"github.com/GallifreyGoTutoural/ggt-rpc/internal/testutil"
+	"sync/atomic"
+	"testing"
+)
+
+func TestXClient_HashServer(t *testing.T) {
+	xc := NewXClient(NewMultiServerDiscovery(nil), ConsistentHashSelect, nil)
+	servers := []string{"tcp@a", "tcp@b", "tcp@c", "tcp@d", "tcp@e"}
+	before := make(map[string]string)
+	counts := make(map[string]int)
+	for i := 0; i < 1000; i++ {
+		key := fmt.Sprint("user-", i)
+		before[key] = xc.hashServer(servers, key)
+		counts[before[key]]++
+	}
+	for _, server := range servers {
+		testutil.Assert(counts[server] > 100, "keys are not spread evenly: %v", counts)
+	}
+	// removing a server only moves its own keys
+	for key, server := range before {
+		after := xc.hashServer(servers[1:], key)
+		testutil.Assert(server == servers[0] || after == server, "key %s moved from %s to %s", key, server, after)
+	}
+}
+
+func TestXClient_ConsistentHash(t *testing.T) {
+	a, b := &Lookup{}, &Lookup{}
+	d := NewMultiServerDiscovery([]string{startServer(t, a), startServer(t, b)})
+	xc := NewXClient(d, ConsistentHashSelect, nil)
+	defer xc.Close()
+	call := func(ctx context.Context, key string) {
+		var reply string
+		err := xc.Call(ctx, "Lookup.Get", key, &reply)
+		testutil.Assert(err == nil && reply == key, "call Lookup.Get error:%v reply:%s", err, reply)
+	}
+	sticky := func(call func(), n int32) {
+		before := [2]int32{atomic.LoadInt32(&a.calls), atomic.LoadInt32(&b.calls)}
+		for i := int32(0); i < n; i++ {
+			call()
+		}
+		ca, cb := atomic.LoadInt32(&a.calls)-before[0], atomic.LoadInt32(&b.calls)-before[1]
+		testutil.Assert(ca == n && cb == 0 || ca == 0 && cb == n, "expect calls with one key on one server, but got %d and %d", ca, cb)
+	}
+
+	t.Run("key from ctx", func(t *testing.T) {
+		ctx := WithHashKey(context.Background(), "user-1")
+		sticky(func() { call(ctx, "x") }, 10)
+	})
+	t.Run("key from args", func(t *testing.T) {
+		xc.SetHashKeyFunc(func(serviceMethod string, args interface{}) string { return args.(string) })
+		sticky(func() { call(context.Background(), "user-2") }, 10)
+	})
+}
@@ -0,0 +1,272 @@
+package xclient
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	. "github.com/GallifreyGoTutoural/ggt-rpc/rpc"
+	"sync"
+	"time"
+)
+
+// HealthCheckFunc checks the server at rpcAddr, nil means healthy.
+type HealthCheckFunc func(ctx context.Context, rpcAddr string) error
+
+// HealthCheckOption configures a HealthCheckedDiscovery.
+type HealthCheckOption struct {
+	Interval           time.Duration   // period of the checks, 0 means 10s
+	Timeout            time.Duration   // timeout of a check including the dial, 0 means 1s
+	HealthyThreshold   int             // consecutive successes to re-add a server, values below 1 mean 2
+	UnhealthyThreshold int             // consecutive failures to remove a server, values below 1 mean 1
+	Check              HealthCheckFunc // nil means calling "Health.Check" for the whole server
+	Option             *Option         // option of the connections of the default check
+}
+
+// healthStatus is the state of the checks of a server.
+type healthStatus struct {
+	unhealthy bool
+	successes int // consecutive successes
+	failures  int // consecutive failures
+}
+
+// HealthCheckedDiscovery decorates a Discovery, leaving the servers failing periodic checks out of selection.
+type HealthCheckedDiscovery struct {
+	Discovery
+	opt HealthCheckOption
+
+	mu      sync.Mutex // protect following
+	status  map[string]*healthStatus
+	clients map[string]*Client // connections of the default check
+	done    chan struct{}
+}
+
+func NewHealthCheckedDiscovery(d Discovery, opt *HealthCheckOption) *HealthCheckedDiscovery {
+	h := &HealthCheckedDiscovery{
+		Discovery: d,
+		status:    make(map[string]*healthStatus),
+		clients:   make(map[string]*Client),
+		done:      make(chan struct{}),
+	}
+	if opt != nil {
+		h.opt = *opt
+	}
+	if h.opt.Interval <= 0 {
+		h.opt.Interval = 10 * time.Second
+	}
+	if h.opt.Timeout <= 0 {
+		h.opt.Timeout = time.Second
+	}
+	// the dial of the default check is bounded by the timeout too, so an unreachable server doesn't stall the checks
+	connOpt := *DefaultOption
+	if h.opt.Option != nil {
+		connOpt = *h.opt.Option
+	}
+	if connOpt.ConnectionTimeoutSec <= 0 || connOpt.ConnectionTimeoutSec > h.opt.Timeout {
+		connOpt.ConnectionTimeoutSec = h.opt.Timeout
+	}
+	h.opt.Option = &connOpt
+	if h.opt.HealthyThreshold < 1 {
+		h.opt.HealthyThreshold = 2
+	}
+	if h.opt.UnhealthyThreshold < 1 {
+		h.opt.UnhealthyThreshold = 1
+	}
+	if h.opt.Check == nil {
+		h.opt.Check = h.check
+	}
+	go h.run()
+	return h
+}
+
+// Healthy reports whether rpcAddr is deemed healthy.
+func (h *HealthCheckedDiscovery) Healthy(rpcAddr string) bool {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	s, ok := h.status[rpcAddr]
+	return !ok || !s.unhealthy
+}
+
+// Get selects a healthy server with the select mode of the decorated discovery.
+func (h *HealthCheckedDiscovery) Get(mode SelectMode) (string, error) {
+	rpcAddr, err := h.Discovery.Get(mode)
+	if err != nil || h.Healthy(rpcAddr) {
+		return rpcAddr, err
+	}
+	servers, err := h.GetAll()
+	if err != nil {
+		return "", err
+	}
+	if len(servers) == 0 {
+		return "", errors.New("rpc discovery: no healthy servers")
+	}
+	// ask the decorated discovery again, so that the select mode still decides
+	for i := 0; i < len(servers); i++ {
+		if addr, err := h.Discovery.Get(mode); err == nil && h.Healthy(addr) {
+			return addr, nil
+		}
+	}
+	return servers[0], nil
+}
+
+// GetAll returns the healthy servers.
+func (h *HealthCheckedDiscovery) GetAll() ([]string, error) {
+	servers, err := h.Discovery.GetAll()
+	if err != nil {
+		return nil, err
+	}
+	healthy := make([]string, 0, len(servers))
+	for _, addr := range servers {
+		if h.Healthy(addr) {
+			healthy = append(healthy, addr)
+		}
+	}
+	return healthy, nil
+}
+
+// Metadata returns the metadata of the server from the decorated discovery, nil if it has none.
+func (h *HealthCheckedDiscovery) Metadata(rpcAddr string) map[string]string {
+	if md, ok := h.Discovery.(MetadataDiscovery); ok {
+		return md.Metadata(rpcAddr)
+	}
+	return nil
+}
+
+// Close stops the checks.
+func (h *HealthCheckedDiscovery) Close() error {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	select {
+	case <-h.done:
+		return nil
+	default:
+	}
+	close(h.done)
+	for rpcAddr, client := range h.clients {
+		_ = client.Close()
+		delete(h.clients, rpcAddr)
+	}
+	return nil
+}
+
+func (h *HealthCheckedDiscovery) run() {
+	t := time.NewTicker(h.opt.Interval)
+	defer t.Stop()
+	for {
+		h.checkAll()
+		select {
+		case <-h.done:
+			return
+		case <-t.C:
+		}
+	}
+}
+
+// checkAll checks every server of the decorated discovery concurrently.
+func (h *HealthCheckedDiscovery) checkAll() {
+	servers, err := h.Discovery.GetAll()
+	if err != nil {
+		return
+	}
+	var wg sync.WaitGroup
+	for _, rpcAddr := range servers {
+		wg.Add(1)
+		go func(rpcAddr string) {
+			defer wg.Done()
+			ctx, cancel := context.WithTimeout(context.Background(), h.opt.Timeout)
+			defer cancel()
+			h.report(rpcAddr, h.opt.Check(ctx, rpcAddr))
+		}(rpcAddr)
+	}
+	wg.Wait()
+	h.forget(servers)
+}
+
+// report records the result of a check of rpcAddr.
+func (h *HealthCheckedDiscovery) report(rpcAddr string, err error) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	s, ok := h.status[rpcAddr]
+	if !ok {
+		s = &healthStatus{}
+		h.status[rpcAddr] = s
+	}
+	if err != nil {
+		s.successes = 0
+		s.failures++
+		if !s.unhealthy && s.failures >= h.opt.UnhealthyThreshold {
+			s.unhealthy = true
+		}
+		return
+	}
+	s.failures = 0
+	s.successes++
+	if s.unhealthy && s.successes >= h.opt.HealthyThreshold {
+		s.unhealthy = false
+	}
+}
+
+// forget drops the state of the servers no longer discovered.
+func (h *HealthCheckedDiscovery) forget(servers []string) {
+	current := make(map[string]bool, len(servers))
+	for _, addr := range servers {
+		current[addr] = true
+	}
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	for addr := range h.status {
+		if !current[addr] {
+			delete(h.status, addr)
+		}
+	}
+	for addr, client := range h.clients {
+		if !current[addr] {
+			_ = client.Close()
+			delete(h.clients, addr)
+		}
+	}
+}
+
+// check is the default check, which calls "Health.Check" for the whole server.
+func (h *HealthCheckedDiscovery) check(ctx context.Context, rpcAddr string) error {
+	client, err := h.client(rpcAddr)
+	if err != nil {
+		return err
+	}
+	var status string
+	if err := client.Call(ctx, "Health.Check", "", &status); err != nil {
+		return err
+	}
+	if status != HealthServing {
+		return fmt.Errorf("rpc discovery: server %s is %s", rpcAddr, status)
+	}
+	return nil
+}
+
+// client returns the connection of the default check to rpcAddr.
+func (h *HealthCheckedDiscovery) client(rpcAddr string) (*Client, error) {
+	h.mu.Lock()
+	client, ok := h.clients[rpcAddr]
+	h.mu.Unlock()
+	if ok && client.IsAvailable() {
+		return client, nil
+	}
+	client, err := XDial(rpcAddr, h.opt.Option)
+	if err != nil {
+		return nil, err
+	}
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	select {
+	case <-h.done:
+		_ = client.Close()
+		return nil, ErrShutdown
+	default:
+	}
+	if old, ok := h.clients[rpcAddr]; ok {
+		_ = old.Close()
+	}
+	h.clients[rpcAddr] = client
+	return client, nil
+}
+
+var _ Discovery = (*HealthCheckedDiscovery)(nil)
@@ -0,0 +1,95 @@
+package xclient
+
+import (
+	"context"
+	"errors"
+	"github.com/GallifreyGoTutoural/ggt-rpc/internal/testutil"
+	. "github.com/GallifreyGoTutoural/ggt-rpc/rpc"
+	"net"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestHealthCheckedDiscovery(t *testing.T) {
+	server := NewServer()
+	health, _ := server.RegisterHealth()
+	l, _ := net.Listen("tcp", "127.0.0.1:0")
+	defer l.Close()
+	go server.Accept(l)
+	checked := "tcp@" + l.Addr().String()
+	unchecked := startServer(t)
+	d := NewHealthCheckedDiscovery(NewMultiServerDiscovery([]string{checked, unchecked, deadServer(t)}),
+		&HealthCheckOption{Interval: 20 * time.Millisecond})
+	defer d.Close()
+	time.Sleep(100 * time.Millisecond)
+
+	servers, err := d.GetAll()
+	testutil.Assert(err == nil && len(servers) == 1 && servers[0] == checked, "GetAll() error:%v servers:%v", err, servers)
+	for i := 0; i < 5; i++ {
+		s, err := d.Get(RoundRobinSelect)
+		testutil.Assert(err == nil && s == checked, "Get() error:%v server:%s", err, s)
+	}
+
+	health.Shutdown()
+	time.Sleep(100 * time.Millisecond)
+	testutil.Assert(!d.Healthy(checked), "expect %s to be removed", checked)
+	_, err = d.Get(RandomSelect)
+	testutil.Assert(err != nil, "expect no healthy servers")
+
+	health.Resume()
+	time.Sleep(100 * time.Millisecond)
+	testutil.Assert(d.Healthy(checked), "expect %s to be re-added", checked)
+
+	t.Run("dial timeout", func(t *testing.T) {
+		d := NewHealthCheckedDiscovery(NewMultiServerDiscovery(nil),
+			&HealthCheckOption{Interval: time.Hour, Timeout: 50 * time.Millisecond, Option: &Option{ConnectionTimeoutSec: time.Minute}})
+		defer d.Close()
+		testutil.Assert(d.opt.Option.ConnectionTimeoutSec == 50*time.Millisecond, "expect the dial to be bounded by the timeout")
+	})
+	t.Run("servers of the decorated discovery", func(t *testing.T) {
+		servers := []string{deadServer(t), checked}
+		d := NewHealthCheckedDiscovery(sharedDiscovery{NewMultiServerDiscovery(nil), servers}, &HealthCheckOption{Interval: time.Hour})
+		defer d.Close()
+		time.Sleep(100 * time.Millisecond)
+		healthy, _ := d.GetAll()
+		testutil.Assert(len(healthy) == 1 && healthy[0] == checked, "unexpected healthy servers: %v", healthy)
+		testutil.Assert(servers[0] != checked, "expect the servers of the decorated discovery to be left untouched: %v", servers)
+	})
+}
+
+// sharedDiscovery returns the same slice from every GetAll.
+type sharedDiscovery struct {
+	*MultiServersDiscovery
+	servers []string
+}
+
+func (d sharedDiscovery) GetAll() ([]string, error) { return d.servers, nil }
+
+func TestHealthCheckedDiscovery_Threshold(t *testing.T) {
+	var mu sync.Mutex
+	results := []error{nil, errors.New("down"), errors.New("down"), nil, nil, nil}
+	next := func() error {
+		mu.Lock()
+		defer mu.Unlock()
+		if len(results) == 0 {
+			return nil
+		}
+		err := results[0]
+		results = results[1:]
+		return err
+	}
+	d := NewHealthCheckedDiscovery(NewMultiServerDiscovery([]string{"tcp@a"}), &HealthCheckOption{
+		Interval:           time.Hour,
+		UnhealthyThreshold: 2,
+		HealthyThreshold:   3,
+		Check:              func(ctx context.Context, rpcAddr string) error { return next() },
+	})
+	defer d.Close()
+	// the first check is made on start
+	time.Sleep(10 * time.Millisecond)
+	for i, want := range []bool{true, false, false, false, true} {
+		d.checkAll()
+		testutil.Assert(d.Healthy("tcp@a") == want, "check %d: expect healthy %v", i+2, want)
+	}
+}
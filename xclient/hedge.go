@@ -0,0 +1,192 @@
+package xclient
+
+import (
+	"context"
+	"errors"
+	"math"
+	"sort"
+	"sync"
+	"time"
+)
+
+// HedgePolicy configures hedged requests of XClient.Call: slow calls are copied to another server
+// and the first reply wins. Only read-only methods should be hedged.
+type HedgePolicy struct {
+	Methods    []string      // methods to hedge, format "Service.Method"
+	Percentile float64       // percentile of recent latencies used as the delay, 0 means 0.95
+	Delay      time.Duration // delay used until enough latencies of the method are recorded, 0 means 100ms
+	MaxHedges  int           // max copies sent besides the first call, values below 1 mean 1
+}
+
+const (
+	latencyWindowSize = 128 // latencies kept per method
+	minLatencySamples = 16  // latencies needed before the percentile is used
+	defaultPercentile = 0.95
+	defaultHedgeDelay = 100 * time.Millisecond
+)
+
+var errAllTried = errors.New("rpc discovery: all servers tried")
+
+// latencyWindow keeps the recent latencies of a method.
+type latencyWindow struct {
+	samples []time.Duration
+	next    int
+}
+
+func (w *latencyWindow) add(d time.Duration) {
+	if len(w.samples) < latencyWindowSize {
+		w.samples = append(w.samples, d)
+		return
+	}
+	w.samples[w.next] = d
+	w.next = (w.next + 1) % latencyWindowSize
+}
+
+// percentile returns the p percentile of the latencies, false if there are not enough of them.
+func (w *latencyWindow) percentile(p float64) (time.Duration, bool) {
+	if len(w.samples) < minLatencySamples {
+		return 0, false
+	}
+	sorted := make([]time.Duration, len(w.samples))
+	copy(sorted, w.samples)
+	sort.Slice(sorted, func(i, j int) bool { return sorted[i] < sorted[j] })
+	i := int(math.Ceil(p*float64(len(sorted)))) - 1
+	if i < 0 {
+		i = 0
+	}
+	return sorted[i], true
+}
+
+// hedger holds a HedgePolicy and the latencies of the hedged methods.
+type hedger struct {
+	policy    HedgePolicy
+	mu        sync.Mutex // protect following
+	latencies map[string]*latencyWindow
+}
+
+func newHedger(policy HedgePolicy) *hedger {
+	if policy.Percentile <= 0 || policy.Percentile >= 1 {
+		policy.Percentile = defaultPercentile
+	}
+	if policy.MaxHedges < 1 {
+		policy.MaxHedges = 1
+	}
+	if policy.Delay <= 0 {
+		policy.Delay = defaultHedgeDelay
+	}
+	return &hedger{policy: policy, latencies: make(map[string]*latencyWindow)}
+}
+
+func (h *hedger) hedged(serviceMethod string) bool {
+	if h == nil {
+		return false
+	}
+	for _, m := range h.policy.Methods {
+		if m == serviceMethod {
+			return true
+		}
+	}
+	return false
+}
+
+// delay returns how long to wait for a reply before sending another copy of the call.
+func (h *hedger) delay(serviceMethod string) time.Duration {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	if w, ok := h.latencies[serviceMethod]; ok {
+		if d, ok := w.percentile(h.policy.Percentile); ok {
+			return d
+		}
+	}
+	return h.policy.Delay
+}
+
+func (h *hedger) record(serviceMethod string, d time.Duration) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	w, ok := h.latencies[serviceMethod]
+	if !ok {
+		w = &latencyWindow{}
+		h.latencies[serviceMethod] = w
+	}
+	w.add(d)
+}
+
+// SetHedgePolicy sets the hedge policy of Call, nil means no hedging.
+// Hedged methods are not retried by the retry policy.
+func (xc *XClient) SetHedgePolicy(policy *HedgePolicy) {
+	xc.mu.Lock()
+	defer xc.mu.Unlock()
+	if policy == nil {
+		xc.hedge = nil
+		return
+	}
+	xc.hedge = newHedger(*policy)
+}
+
+// hedgedCall calls serviceMethod, sending another copy to an untried server when the delay passes or a copy fails.
+func (xc *XClient) hedgedCall(h *hedger, ctx context.Context, serviceMethod string, args, reply interface{}) error {
+	type result struct {
+		clone interface{}
+		err   error
+	}
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	results := make(chan result, h.policy.MaxHedges+1)
+	tried := make(map[string]bool)
+	send := func() error {
+		rpcAddr, err := xc.selectServer(ctx, serviceMethod, args, tried)
+		if err != nil {
+			return err
+		}
+		if tried[rpcAddr] {
+			return errAllTried
+		}
+		tried[rpcAddr] = true
+		go func() {
+			clone := cloneReply(reply)
+			start := time.Now()
+			err := xc.call(rpcAddr, ctx, serviceMethod, args, clone)
+			// a copy cancelled because another one won would have taken at least this long
+			if err == nil || ctx.Err() != nil {
+				h.record(serviceMethod, time.Since(start))
+			}
+			results <- result{clone: clone, err: err}
+		}()
+		return nil
+	}
+
+	if err := send(); err != nil {
+		return err
+	}
+	pending, hedges := 1, 0
+	hedge := func() {
+		if hedges < h.policy.MaxHedges && ctx.Err() == nil && send() == nil {
+			pending++
+			hedges++
+		}
+	}
+	delay := h.delay(serviceMethod)
+	timer := time.NewTimer(delay)
+	defer timer.Stop()
+	var e error
+	for pending > 0 {
+		select {
+		case <-timer.C:
+			hedge()
+			timer.Reset(delay)
+		case r := <-results:
+			pending--
+			if r.err == nil {
+				setReply(reply, r.clone)
+				return nil
+			}
+			e = r.err
+			// don't wait for the delay if the server is unreachable
+			if isConnError(r.err) {
+				hedge()
+			}
+		}
+	}
+	return e
+}
@@ -0,0 +1,55 @@
+package xclient
+
+import (
+	"context"
+	"github.com/GallifreyGoTutoural/ggt-rpc/internal/testutil"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// Lookup answers after its delay, and counts the calls.
+type Lookup struct {
+	delay time.Duration
+	calls int32
+}
+
+func (l *Lookup) Get(key string, reply *string) error {
+	atomic.AddInt32(&l.calls, 1)
+	time.Sleep(l.delay)
+	*reply = key
+	return nil
+}
+
+func TestXClient_Hedge(t *testing.T) {
+	slow, fast := &Lookup{delay: time.Second}, &Lookup{}
+	d := NewMultiServerDiscovery([]string{startServer(t, slow), startServer(t, fast)})
+	xc := NewXClient(d, RoundRobinSelect, nil)
+	defer xc.Close()
+	xc.SetHedgePolicy(&HedgePolicy{Methods: []string{"Lookup.Get"}, Delay: 50 * time.Millisecond})
+
+	for i := 0; i < 4; i++ {
+		start := time.Now()
+		var reply string
+		err := xc.Call(context.Background(), "Lookup.Get", "k", &reply)
+		testutil.Assert(err == nil && reply == "k", "call Lookup.Get error:%v reply:%s", err, reply)
+		testutil.Assert(time.Since(start) < 500*time.Millisecond, "expect the hedged call to be answered by the fast server")
+	}
+	calls := atomic.LoadInt32(&fast.calls)
+	testutil.Assert(calls == 4, "expect 4 calls on the fast server, but got %d", calls)
+	time.Sleep(50 * time.Millisecond)
+	xc.hedge.mu.Lock()
+	samples := len(xc.hedge.latencies["Lookup.Get"].samples)
+	xc.hedge.mu.Unlock()
+	testutil.Assert(samples > 4, "expect the latencies of the cancelled copies to be recorded, but got %d", samples)
+
+	t.Run("percentile", func(t *testing.T) {
+		testutil.Assert(newHedger(HedgePolicy{}).delay("Lookup.Get") == defaultHedgeDelay, "expect the default delay")
+		h := newHedger(HedgePolicy{Percentile: 0.5, Delay: time.Second})
+		testutil.Assert(h.delay("Lookup.Get") == time.Second, "expect the configured delay without enough latencies")
+		for i := 1; i <= 100; i++ {
+			h.record("Lookup.Get", time.Duration(i)*time.Millisecond)
+		}
+		testutil.Assert(h.delay("Lookup.Get") == 50*time.Millisecond, "expect the median latency, but got %s", h.delay("Lookup.Get"))
+	})
+}
@@ -0,0 +1,85 @@
+package xclient
+
+import (
+	"context"
+	"errors"
+	. "github.com/GallifreyGoTutoural/ggt-rpc/rpc"
+	"io"
+	"net"
+	"time"
+)
+
+// RetryPolicy configures the retries of XClient.Call.
+// Only methods declared idempotent, in Idempotent or Methods, are retried,
+// each retry goes to a server not tried yet if there is one.
+type RetryPolicy struct {
+	MaxAttempts     int                     // attempts including the first one, values below 2 mean no retry
+	Backoff         Backoff                 // backoff between attempts
+	RetryableErrors []error                 // errors to retry, nil means connection-level errors
+	Idempotent      []string                // methods safe to retry, format "Service.Method"
+	Methods         map[string]*RetryPolicy // per-method policies of idempotent methods, keyed by "Service.Method"
+}
+
+// policyOf returns the policy for serviceMethod, nil if the method must not be retried.
+func (p *RetryPolicy) policyOf(serviceMethod string) *RetryPolicy {
+	if p == nil {
+		return nil
+	}
+	if override, ok := p.Methods[serviceMethod]; ok {
+		return override
+	}
+	if !p.isIdempotent(serviceMethod) {
+		return nil
+	}
+	return p
+}
+
+func (p *RetryPolicy) isIdempotent(serviceMethod string) bool {
+	for _, m := range p.Idempotent {
+		if m == serviceMethod {
+			return true
+		}
+	}
+	return false
+}
+
+// retryable reports whether err is worth retrying.
+func (p *RetryPolicy) retryable(err error) bool {
+	if p.RetryableErrors == nil {
+		return isConnError(err)
+	}
+	for _, e := range p.RetryableErrors {
+		// server errors are sent as their message
+		if errors.Is(err, e) || err.Error() == e.Error() {
+			return true
+		}
+	}
+	return false
+}
+
+// wait sleeps before the retry after attempt, and returns false if ctx is done or its deadline comes first.
+func (p *RetryPolicy) wait(ctx context.Context, attempt int) bool {
+	return sleep(ctx, p.Backoff.Delay(attempt))
+}
+
+// sleep sleeps for delay, and returns false if ctx is done or its deadline comes first.
+func sleep(ctx context.Context, delay time.Duration) bool {
+	if deadline, ok := ctx.Deadline(); ok && time.Until(deadline) < delay {
+		return false
+	}
+	t := time.NewTimer(delay)
+	defer t.Stop()
+	select {
+	case <-ctx.Done():
+		return false
+	case <-t.C:
+		return true
+	}
+}
+
+// isConnError reports whether err is an error of the connection rather than of the called method.
+func isConnError(err error) bool {
+	var netErr net.Error
+	return errors.Is(err, ErrShutdown) || errors.Is(err, ErrConnectTimeout) || errors.Is(err, ErrBreakerOpen) ||
+		errors.Is(err, io.EOF) || errors.Is(err, io.ErrUnexpectedEOF) || errors.As(err, &netErr)
+}
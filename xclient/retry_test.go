@@ -0,0 +1,59 @@
+package xclient
+
+import (
+	"context"
+	"github.com/GallifreyGoTutoural/ggt-rpc/internal/testutil"
+	. "github.com/GallifreyGoTutoural/ggt-rpc/rpc"
+	"testing"
+	"time"
+)
+
+func TestXClient_Retry(t *testing.T) {
+	d := NewMultiServerDiscovery([]string{deadServer(t), startServer(t)})
+	xc := NewXClient(d, RoundRobinSelect, nil)
+	defer xc.Close()
+	xc.SetRetryPolicy(&RetryPolicy{
+		MaxAttempts: 2,
+		Backoff:     Backoff{Base: time.Millisecond},
+		Idempotent:  []string{"Foo.Sum"},
+	})
+
+	t.Run("idempotent", func(t *testing.T) {
+		for i := 0; i < 4; i++ {
+			var reply int
+			err := xc.Call(context.Background(), "Foo.Sum", &Args{Num1: 1, Num2: 2}, &reply)
+			testutil.Assert(err == nil && reply == 3, "call Foo.Sum error:%v reply:%d", err, reply)
+		}
+	})
+	t.Run("not idempotent", func(t *testing.T) {
+		failed := 0
+		for i := 0; i < 4; i++ {
+			var reply int
+			if err := xc.Call(context.Background(), "Foo.Sleep", &Args{}, &reply); err != nil {
+				failed++
+			}
+		}
+		testutil.Assert(failed == 2, "expect 2 of 4 calls to fail without retry, but %d failed", failed)
+	})
+	t.Run("per-method policy", func(t *testing.T) {
+		xc := NewXClient(d, RoundRobinSelect, nil)
+		defer xc.Close()
+		xc.SetRetryPolicy(&RetryPolicy{Methods: map[string]*RetryPolicy{"Foo.Sleep": {MaxAttempts: 2}}})
+		for i := 0; i < 4; i++ {
+			var reply int
+			err := xc.Call(context.Background(), "Foo.Sleep", &Args{}, &reply)
+			testutil.Assert(err == nil, "expect Foo.Sleep to be retried, but got %v", err)
+		}
+	})
+	t.Run("deadline", func(t *testing.T) {
+		xc := NewXClient(NewMultiServerDiscovery([]string{deadServer(t)}), RandomSelect, nil)
+		defer xc.Close()
+		xc.SetRetryPolicy(&RetryPolicy{MaxAttempts: 5, Backoff: Backoff{Base: time.Second}, Idempotent: []string{"Foo.Sum"}})
+		ctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+		defer cancel()
+		start := time.Now()
+		var reply int
+		err := xc.Call(ctx, "Foo.Sum", &Args{}, &reply)
+		testutil.Assert(err != nil && time.Since(start) < 500*time.Millisecond, "expect to give up before the deadline, error:%v", err)
+	})
+}
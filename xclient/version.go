@@ -0,0 +1,57 @@
+package xclient
+
+import (
+	"context"
+	"errors"
+	. "github.com/GallifreyGoTutoural/ggt-rpc/rpc"
+	"github.com/GallifreyGoTutoural/ggt-rpc/version"
+	"strings"
+)
+
+// ErrNoCompatibleServer is returned when no server advertises a version matching the constraint of the call.
+var ErrNoCompatibleServer = errors.New("rpc xclient: no server with a compatible version")
+
+// MetadataDiscovery is implemented by discoveries knowing the metadata of the servers,
+// which XClient needs to route calls with a version constraint, see rpc.WithVersion.
+type MetadataDiscovery interface {
+	Discovery
+	Metadata(rpcAddr string) map[string]string
+}
+
+// compatible returns whether a server may serve the version constraint of ctx for the service of serviceMethod.
+// Servers advertising no version of the service are, and left to check the constraint themselves.
+func (xc *XClient) compatible(ctx context.Context, serviceMethod string) func(rpcAddr string) bool {
+	constraint := MetadataFromContext(ctx)[version.MetadataKey]
+	md, ok := xc.d.(MetadataDiscovery)
+	if constraint == "" || !ok {
+		return func(string) bool { return true }
+	}
+	service := serviceMethod
+	if dot := strings.LastIndex(serviceMethod, "."); dot >= 0 {
+		service = serviceMethod[:dot]
+	}
+	key := version.Key(service)
+	return func(rpcAddr string) bool {
+		versions, ok := md.Metadata(rpcAddr)[key]
+		return !ok || version.AnyMatches(versions, constraint)
+	}
+}
+
+// serversFor returns the servers of the discovery compatible with the call.
+func (xc *XClient) serversFor(ctx context.Context, serviceMethod string) ([]string, error) {
+	servers, err := xc.d.GetAll()
+	if err != nil {
+		return nil, err
+	}
+	compatible := xc.compatible(ctx, serviceMethod)
+	filtered := make([]string, 0, len(servers))
+	for _, addr := range servers {
+		if compatible(addr) {
+			filtered = append(filtered, addr)
+		}
+	}
+	if len(filtered) == 0 && len(servers) > 0 {
+		return nil, ErrNoCompatibleServer
+	}
+	return filtered, nil
+}
@@ -0,0 +1,73 @@
+package xclient
+
+import (
+	"context"
+	"errors"
+	"github.com/GallifreyGoTutoural/ggt-rpc/internal/testutil"
+	. "github.com/GallifreyGoTutoural/ggt-rpc/rpc"
+	"net"
+	"testing"
+)
+
+// startVersionedServer starts a server with a Replica of the version registered,
+// and returns its address and version metadata.
+func startVersionedServer(t *testing.T, version string) (string, map[string]string) {
+	server := NewServer()
+	_ = server.RegisterVersion("Replica", version, &Replica{value: version})
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { _ = l.Close() })
+	go server.Accept(l)
+	return "tcp@" + l.Addr().String(), server.VersionMetadata()
+}
+
+func TestXClient_Version(t *testing.T) {
+	v1, md1 := startVersionedServer(t, "1.2")
+	v2, md2 := startVersionedServer(t, "2.0")
+	d := NewMultiServerDiscovery([]string{v1, v2})
+	d.UpdateMetadata(v1, md1)
+	d.UpdateMetadata(v2, md2)
+
+	for _, mode := range []SelectMode{RandomSelect, RoundRobinSelect, P2CSelect} {
+		xc := NewXClient(d, mode, nil)
+		for i := 0; i < 10; i++ {
+			var reply string
+			err := xc.Call(WithVersion(context.Background(), "2"), "Replica.Read", "key", &reply)
+			testutil.Assert(err == nil && reply == "2.0", "mode %d: call error:%v reply:%s", mode, err, reply)
+		}
+		_ = xc.Close()
+	}
+
+	xc := NewXClient(d, RandomSelect, nil)
+	defer xc.Close()
+	t.Run("no compatible server", func(t *testing.T) {
+		var reply string
+		err := xc.Call(WithVersion(context.Background(), "3"), "Replica.Read", "key", &reply)
+		testutil.Assert(errors.Is(err, ErrNoCompatibleServer), "expect ErrNoCompatibleServer, but got %v", err)
+	})
+	t.Run("broadcast", func(t *testing.T) {
+		results, err := xc.BroadcastAll(WithVersion(context.Background(), "1"), "Replica.Read", "key", new(string))
+		testutil.Assert(err == nil && len(results) == 1, "BroadcastAll() error:%v results:%v", err, results)
+		testutil.Assert(*results[v1].Reply.(*string) == "1.2", "expect the reply of %s, but got %v", v1, results)
+	})
+	t.Run("no version metadata", func(t *testing.T) {
+		xc := NewXClient(NewMultiServerDiscovery([]string{v1}), RandomSelect, nil)
+		defer xc.Close()
+		var reply string
+		err := xc.Call(WithVersion(context.Background(), "1"), "Replica.Read", "key", &reply)
+		testutil.Assert(err == nil && reply == "1.2", "call error:%v reply:%s", err, reply)
+		err = xc.Call(WithVersion(context.Background(), "2"), "Replica.Read", "key", &reply)
+		testutil.Assert(err != nil && !errors.Is(err, ErrNoCompatibleServer), "expect the server to reject the call, but got %v", err)
+	})
+	t.Run("health checked discovery", func(t *testing.T) {
+		h := NewHealthCheckedDiscovery(d, &HealthCheckOption{Check: func(context.Context, string) error { return nil }})
+		defer h.Close()
+		xc := NewXClient(h, RandomSelect, nil)
+		defer xc.Close()
+		var reply string
+		err := xc.Call(WithVersion(context.Background(), "1.2"), "Replica.Read", "key", &reply)
+		testutil.Assert(err == nil && reply == "1.2", "call error:%v reply:%s", err, reply)
+	})
+}
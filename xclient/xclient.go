@@ -2,6 +2,7 @@ package xclient
 
 import (
 	"context"
+	"errors"
 	. "github.com/GallifreyGoTutoural/ggt-rpc/rpc"
 	"io"
 	"reflect"
@@ -9,11 +10,19 @@ import (
 )
 
 type XClient struct {
-	d       Discovery
-	mode    SelectMode
-	opt     *Option
-	mu      sync.Mutex
-	clients map[string]*Client
+	d           Discovery
+	mode        SelectMode
+	opt         *Option
+	mu          sync.Mutex
+	clients     map[string]*Client
+	retry       *RetryPolicy
+	hedge       *hedger
+	breakers    *breakers
+	hashKeyFunc HashKeyFunc
+	failMode    FailMode
+
+	statsMu sync.Mutex // protect following
+	stats   map[string]*serverStats
 }
 
 func NewXClient(d Discovery, mode SelectMode, opt *Option) *XClient {
@@ -22,9 +31,17 @@ func NewXClient(d Discovery, mode SelectMode, opt *Option) *XClient {
 		mode:    mode,
 		opt:     opt,
 		clients: make(map[string]*Client),
+		stats:   make(map[string]*serverStats),
 	}
 }
 
+// SetRetryPolicy sets the retry policy of Call, nil means no retry.
+func (xc *XClient) SetRetryPolicy(policy *RetryPolicy) {
+	xc.mu.Lock()
+	defer xc.mu.Unlock()
+	xc.retry = policy
+}
+
 func (xc *XClient) Close() error {
 	xc.mu.Lock()
 	defer xc.mu.Unlock()
@@ -36,13 +53,22 @@ func (xc *XClient) Close() error {
 }
 
 var _ io.Closer = (*XClient)(nil)
+var _ Caller = (*XClient)(nil)
 
 func (xc *XClient) dial(rpcAddr string) (*Client, error) {
 	xc.mu.Lock()
 	defer xc.mu.Unlock()
 	client, ok := xc.clients[rpcAddr]
 	if ok && !client.IsAvailable() {
-		_ = client.Close()
+		if client.IsDraining() {
+			// the server closes the connection once the calls already sent are answered
+			go func(client *Client) {
+				<-client.Done()
+				_ = client.Close()
+			}(client)
+		} else {
+			_ = client.Close()
+		}
 		delete(xc.clients, rpcAddr)
 		client = nil
 	}
@@ -58,47 +84,194 @@ func (xc *XClient) dial(rpcAddr string) (*Client, error) {
 }
 
 func (xc *XClient) call(rpcAddr string, ctx context.Context, serviceMethod string, args, reply interface{}) error {
+	xc.mu.Lock()
+	bs := xc.breakers
+	xc.mu.Unlock()
+	ok, trial := bs.acquire(rpcAddr, xc.d.GetAll)
+	if !ok {
+		return ErrBreakerOpen
+	}
+	start := xc.begin(rpcAddr)
 	client, err := xc.dial(rpcAddr)
-	if err != nil {
-		return err
+	if err == nil {
+		err = client.Call(ctx, serviceMethod, args, reply)
 	}
-	return client.Call(ctx, serviceMethod, args, reply)
+	xc.end(rpcAddr, ctx, start, err)
+	bs.done(rpcAddr, ctx, trial, err)
+	return err
 }
 
+// Call invokes the named function on a server selected from the discovery.
+// If the retry policy allows, failed calls are retried on servers not tried yet, until ctx is done.
+// Methods of the hedge policy are hedged instead. A fail mode, set on the XClient or by WithFailMode,
+// replaces both policies.
 func (xc *XClient) Call(ctx context.Context, serviceMethod string, args, reply interface{}) error {
+	if mode := xc.failModeOf(ctx); mode != 0 {
+		return xc.callWithFailMode(mode, ctx, serviceMethod, args, reply)
+	}
+	xc.mu.Lock()
+	policy := xc.retry.policyOf(serviceMethod)
+	h := xc.hedge
+	xc.mu.Unlock()
+	if h.hedged(serviceMethod) {
+		return xc.hedgedCall(h, ctx, serviceMethod, args, reply)
+	}
+	tried := make(map[string]bool)
+	for attempt := 0; ; attempt++ {
+		rpcAddr, err := xc.selectServer(ctx, serviceMethod, args, tried)
+		if err != nil {
+			return err
+		}
+		tried[rpcAddr] = true
+		err = xc.call(rpcAddr, ctx, serviceMethod, args, reply)
+		if err == nil || policy == nil || attempt+1 >= policy.MaxAttempts || !policy.retryable(err) {
+			return err
+		}
+		if !policy.wait(ctx, attempt) {
+			return err
+		}
+	}
+}
+
+// selectServer selects a server for the call with the select mode, skipping servers whose breaker is open
+// or whose version doesn't match the constraint of ctx, and preferring servers not tried yet.
+func (xc *XClient) selectServer(ctx context.Context, serviceMethod string, args interface{}, tried map[string]bool) (string, error) {
+	compatible := xc.compatible(ctx, serviceMethod)
+	// modes selected by XClient from the candidates
+	var pick func(servers []string) string
+	switch xc.mode {
+	case ConsistentHashSelect:
+		key := xc.hashKey(ctx, serviceMethod, args)
+		pick = func(servers []string) string { return xc.hashServer(servers, key) }
+	case P2CSelect:
+		pick = xc.p2cServer
+	case LeastOutstandingSelect:
+		pick = xc.leastOutstandingServer
+	}
+	if pick != nil {
+		servers, err := xc.candidates(tried, compatible)
+		if err != nil {
+			return "", err
+		}
+		return pick(servers), nil
+	}
+	xc.mu.Lock()
+	bs := xc.breakers
+	xc.mu.Unlock()
+	usable := func(addr string) bool { return compatible(addr) && bs.available(addr) }
 	rpcAddr, err := xc.d.Get(xc.mode)
+	if err != nil || !tried[rpcAddr] && usable(rpcAddr) {
+		return rpcAddr, err
+	}
+	servers, err := xc.d.GetAll()
 	if err != nil {
-		return err
+		return "", err
+	}
+	// ask the discovery again, so that the select mode still decides
+	for i := 1; i < len(servers); i++ {
+		if addr, err := xc.d.Get(xc.mode); err == nil && !tried[addr] && usable(addr) {
+			return addr, nil
+		}
+	}
+	var fallback string // a server already tried
+	if usable(rpcAddr) {
+		fallback = rpcAddr
 	}
-	return xc.call(rpcAddr, ctx, serviceMethod, args, reply)
+	hasCompatible := false
+	for _, addr := range servers {
+		if !compatible(addr) {
+			continue
+		}
+		hasCompatible = true
+		if !bs.available(addr) {
+			continue
+		}
+		if !tried[addr] {
+			return addr, nil
+		}
+		if fallback == "" {
+			fallback = addr
+		}
+	}
+	if !hasCompatible {
+		return "", ErrNoCompatibleServer
+	}
+	if fallback == "" {
+		return "", ErrBreakerOpen
+	}
+	return fallback, nil
 }
 
-func (xc *XClient) Broadcast(ctx context.Context, serviceMethod string, args, reply interface{}) error {
+// candidates returns the compatible servers whose breaker isn't open, only the ones not tried yet if there are any.
+func (xc *XClient) candidates(tried map[string]bool, compatible func(rpcAddr string) bool) ([]string, error) {
+	xc.mu.Lock()
+	bs := xc.breakers
+	xc.mu.Unlock()
 	servers, err := xc.d.GetAll()
+	if err != nil {
+		return nil, err
+	}
+	if len(servers) == 0 {
+		return nil, errors.New("rpc discovery: no available servers")
+	}
+	var available, untried []string
+	hasCompatible := false
+	for _, addr := range servers {
+		if !compatible(addr) {
+			continue
+		}
+		hasCompatible = true
+		if !bs.available(addr) {
+			continue
+		}
+		available = append(available, addr)
+		if !tried[addr] {
+			untried = append(untried, addr)
+		}
+	}
+	if !hasCompatible {
+		return nil, ErrNoCompatibleServer
+	}
+	if len(available) == 0 {
+		return nil, ErrBreakerOpen
+	}
+	if len(untried) > 0 {
+		return untried, nil
+	}
+	return available, nil
+}
+
+// Broadcast invokes the named function on every server, and returns the first error if any.
+// Servers whose breaker is open are skipped.
+func (xc *XClient) Broadcast(ctx context.Context, serviceMethod string, args, reply interface{}) error {
+	servers, err := xc.serversFor(ctx, serviceMethod)
 	if err != nil {
 		return err
 	}
 	var wg sync.WaitGroup
 	var mu sync.Mutex
 	var e error
+	skipped := 0
 	replyDone := reply == nil
 	ctx, cancel := context.WithCancel(ctx)
 	for _, rpcAddr := range servers {
 		wg.Add(1)
 		go func(rpcAddr string) {
 			defer wg.Done()
-			var cloneReply interface{}
-			if reply != nil {
-				cloneReply = reflect.New(reflect.ValueOf(reply).Elem().Type()).Interface()
-			}
-			err := xc.call(rpcAddr, ctx, serviceMethod, args, cloneReply)
+			clone := cloneReply(reply)
+			err := xc.call(rpcAddr, ctx, serviceMethod, args, clone)
 			mu.Lock()
+			if errors.Is(err, ErrBreakerOpen) {
+				skipped++
+				mu.Unlock()
+				return
+			}
 			if err != nil && e == nil {
 				e = err
 				cancel()
 			}
 			if err == nil && !replyDone {
-				reflect.ValueOf(reply).Elem().Set(reflect.ValueOf(cloneReply).Elem())
+				setReply(reply, clone)
 				replyDone = true
 			}
 			mu.Unlock()
@@ -106,5 +279,24 @@ func (xc *XClient) Broadcast(ctx context.Context, serviceMethod string, args, re
 	}
 	wg.Wait()
 	cancel()
+	if e == nil && skipped == len(servers) && skipped > 0 {
+		return ErrBreakerOpen
+	}
 	return e
 }
+
+// cloneReply returns a pointer to a new value of the type reply points to, so that concurrent calls
+// don't write to reply at the same time. It returns nil if reply is nil.
+func cloneReply(reply interface{}) interface{} {
+	if reply == nil {
+		return nil
+	}
+	return reflect.New(reflect.ValueOf(reply).Elem().Type()).Interface()
+}
+
+// setReply sets the value reply points to to the value clone points to.
+func setReply(reply, clone interface{}) {
+	if reply != nil {
+		reflect.ValueOf(reply).Elem().Set(reflect.ValueOf(clone).Elem())
+	}
+}
@@ -0,0 +1,43 @@
+package xclient
+
+import (
+	"context"
+	"github.com/GallifreyGoTutoural/ggt-rpc/internal/testutil"
+	. "github.com/GallifreyGoTutoural/ggt-rpc/rpc"
+	"net"
+	"testing"
+)
+
+// startServer starts a server with Foo and rcvrs registered, and returns its address.
+func startServer(t *testing.T, rcvrs ...interface{}) string {
+	var foo Foo
+	server := NewServer()
+	_ = server.Register(&foo)
+	for _, rcvr := range rcvrs {
+		_ = server.Register(rcvr)
+	}
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { _ = l.Close() })
+	go server.Accept(l)
+	return "tcp@" + l.Addr().String()
+}
+
+// deadServer returns the address of a closed listener.
+func deadServer(t *testing.T) string {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	_ = l.Close()
+	return "tcp@" + l.Addr().String()
+}
+
+func TestXClient_CallTyped(t *testing.T) {
+	xc := NewXClient(NewMultiServerDiscovery([]string{startServer(t)}), RandomSelect, nil)
+	defer xc.Close()
+	sum, err := CallTyped[Args, int](context.Background(), xc, "Foo.Sum", Args{Num1: 1, Num2: 2})
+	testutil.Assert(err == nil && sum == 3, "call Foo.Sum error:%v reply:%d", err, sum)
+}